@@ -0,0 +1,174 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/sign"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+// bundleSigner holds what is needed to sign with Fulcio, a transparency log
+// and optionally a TSA, as a Sigstore client would.
+type bundleSigner struct {
+	keypair sign.Keypair
+	opts    sign.BundleOptions
+	claims  *tokenClaims
+	// useSignedTimestamps is set when bundles carry a TSA timestamp,
+	// otherwise the integrated time of the log entry is used.
+	useSignedTimestamps bool
+}
+
+// bundleRekorService picks the Rekor service to sign bundles with. Rekor v2
+// is preferred when it is available alongside a TSA, as Rekor v2 entries
+// carry no integrated timestamp.
+func bundleRekorService(rekorV1Services, rekorV2Services, tsaServices []root.Service) (root.Service, error) {
+	switch {
+	case len(rekorV2Services) > 0 && len(tsaServices) > 0:
+		return rekorV2Services[0], nil
+	case len(rekorV1Services) > 0:
+		return rekorV1Services[0], nil
+	default:
+		return root.Service{}, fmt.Errorf("no Rekor service available to sign a bundle with")
+	}
+}
+
+// newBundleSigner fetches an identity token and generates an ephemeral key
+// to sign bundles with fulcioService, rekorService and the first TSA.
+func newBundleSigner(ctx context.Context, fulcioService, rekorService root.Service, tsaServices []root.Service) (*bundleSigner, error) {
+	tok, err := identityToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := parseTokenClaims(tok)
+	if err != nil {
+		return nil, err
+	}
+
+	keypair, err := sign.NewEphemeralKeypair(nil)
+	if err != nil {
+		return nil, fmt.Errorf("generating keypair: %w", err)
+	}
+
+	s := &bundleSigner{
+		keypair: keypair,
+		claims:  claims,
+		opts: sign.BundleOptions{
+			Context: ctx,
+			CertificateProvider: sign.NewFulcio(&sign.FulcioOptions{
+				BaseURL: fulcioService.URL,
+				Retries: retries,
+			}),
+			CertificateProviderOptions: &sign.CertificateProviderOptions{
+				IDToken: tok,
+			},
+			TransparencyLogs: []sign.Transparency{
+				sign.NewRekor(&sign.RekorOptions{
+					BaseURL: rekorService.URL,
+					Retries: retries,
+					Version: rekorService.MajorAPIVersion,
+				}),
+			},
+		},
+	}
+	if len(tsaServices) > 0 {
+		s.opts.TimestampAuthorities = []*sign.TimestampAuthority{
+			sign.NewTimestampAuthority(&sign.TimestampAuthorityOptions{
+				URL:     tsaServices[0].URL,
+				Retries: retries,
+			}),
+		}
+		s.useSignedTimestamps = true
+	}
+	return s, nil
+}
+
+// expectedIssuer returns the OIDC issuer signed bundles are verified
+// against, which is the configured issuer or else the token's issuer.
+func (s *bundleSigner) expectedIssuer() string {
+	if identityIssuer != "" {
+		return identityIssuer
+	}
+	return s.claims.Issuer
+}
+
+// bundleSignVerifyEndpoint signs an artifact the way a Sigstore client does,
+// producing a complete bundle with a Fulcio certificate, a transparency log
+// entry and, when a TSA is available, a signed timestamp, and then verifies
+// the bundle against the trusted root and the expected identity.
+func bundleSignVerifyEndpoint(ctx context.Context, fulcioService root.Service, rekorV1Services, rekorV2Services, tsaServices []root.Service, trustedRoot *root.TrustedRoot) (err error) {
+	rekorService, err := bundleRekorService(rekorV1Services, rekorV2Services, tsaServices)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		bundleSignVerifyCounter.With(prometheus.Labels{
+			hostLabel:    rekorService.URL,
+			successLabel: strconv.FormatBool(err == nil),
+		}).Inc()
+	}()
+
+	signer, err := newBundleSigner(ctx, fulcioService, rekorService, tsaServices)
+	if err != nil {
+		return err
+	}
+
+	artifact := []byte(time.Now().String())
+	pb, err := sign.Bundle(&sign.PlainData{Data: artifact}, signer.keypair, signer.opts)
+	if err != nil {
+		return fmt.Errorf("signing bundle: %w", err)
+	}
+	b, err := bundle.NewBundle(pb)
+	if err != nil {
+		return fmt.Errorf("loading bundle: %w", err)
+	}
+
+	verifierOpts := []verify.VerifierOption{
+		verify.WithSignedCertificateTimestamps(1),
+		verify.WithTransparencyLog(1),
+	}
+	if signer.useSignedTimestamps {
+		verifierOpts = append(verifierOpts, verify.WithSignedTimestamps(1))
+	} else {
+		verifierOpts = append(verifierOpts, verify.WithIntegratedTimestamps(1))
+	}
+	verifier, err := verify.NewVerifier(trustedRoot, verifierOpts...)
+	if err != nil {
+		return fmt.Errorf("creating verifier: %w", err)
+	}
+	identity, err := verify.NewShortCertificateIdentity(signer.expectedIssuer(), "", "", identitySANRegexp)
+	if err != nil {
+		return fmt.Errorf("creating identity policy: %w", err)
+	}
+	policy := verify.NewPolicy(verify.WithArtifact(bytes.NewReader(artifact)), verify.WithCertificateIdentity(identity))
+	if _, err := verifier.Verify(b, policy); err != nil {
+		return fmt.Errorf("verifying bundle: %w", err)
+	}
+	if bundleCorpusDir != "" {
+		// a corpus write failure does not make the signing path unhealthy
+		if err := saveBundleToCorpus(b); err != nil {
+			Logger.Errorf("error saving bundle to corpus: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/fulcio/certificate"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// fulcioCertLifetimeTolerance is how far the validity period of an issued
+// certificate may differ from --fulcio-cert-lifetime.
+const fulcioCertLifetimeTolerance = time.Minute
+
+// activeFulcioCA returns the certificate authority in the trusted root that
+// is currently active for fulcioService.
+func activeFulcioCA(fulcioService root.Service, trustedRoot *root.TrustedRoot) (*root.FulcioCertificateAuthority, error) {
+	now := time.Now()
+	for _, ca := range trustedRoot.FulcioCertificateAuthorities() {
+		if fulcioCA, ok := ca.(*root.FulcioCertificateAuthority); ok {
+			isActive := now.After(fulcioCA.ValidityPeriodStart) && (fulcioCA.ValidityPeriodEnd.IsZero() || now.Before(fulcioCA.ValidityPeriodEnd))
+			if fulcioCA.URI == fulcioService.URL && isActive {
+				return fulcioCA, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("could not find an active Fulcio CA for URI %s in trusted root", fulcioService.URL)
+}
+
+// verifyFulcioCertificate checks a certificate issued by Fulcio: it must
+// chain to the active CA in the trusted root, name the identity and issuer
+// of the token of identityType it was requested with, be usable only for
+// code signing, certify the submitted key, and be valid for the expected
+// lifetime starting now. Every violation is reported separately.
+func verifyFulcioCertificate(host string, leaf *x509.Certificate, ca *root.FulcioCertificateAuthority, submitted crypto.PublicKey, identityType string, claims *tokenClaims) error {
+	var errs []error
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Root)
+	intermediates := x509.NewCertPool()
+	for _, c := range ca.Intermediates {
+		intermediates.AddCert(c)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   leaf.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		errs = append(errs, verificationFailure(host, reasonChainInvalid, err))
+	}
+
+	sans := cryptoutils.GetSubjectAlternateNames(leaf)
+	if err := checkSAN(sans, identityType, claims); err != nil {
+		errs = append(errs, verificationFailure(host, reasonSANMismatch, err))
+	}
+
+	if ext, err := certificate.ParseExtensions(leaf.Extensions); err != nil {
+		errs = append(errs, verificationFailure(host, reasonIssuerMismatch, fmt.Errorf("parsing Fulcio extensions: %w", err)))
+	} else if issuers := claims.certificateIssuers(); !slices.Contains(issuers, ext.Issuer) {
+		errs = append(errs, verificationFailure(host, reasonIssuerMismatch, fmt.Errorf("got %q, want one of %q", ext.Issuer, issuers)))
+	}
+
+	switch {
+	case leaf.IsCA:
+		errs = append(errs, verificationFailure(host, reasonKeyUsage, errors.New("leaf certificate is a CA")))
+	case leaf.KeyUsage != x509.KeyUsageDigitalSignature:
+		errs = append(errs, verificationFailure(host, reasonKeyUsage, fmt.Errorf("got key usage %d, want digital signature only", leaf.KeyUsage)))
+	case !slices.Equal(leaf.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}):
+		errs = append(errs, verificationFailure(host, reasonKeyUsage, fmt.Errorf("got extended key usages %v, want code signing only", leaf.ExtKeyUsage)))
+	}
+
+	if err := cryptoutils.EqualKeys(leaf.PublicKey, submitted); err != nil {
+		errs = append(errs, verificationFailure(host, reasonPublicKeyMismatch, err))
+	}
+
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+	if (lifetime - fulcioCertLifetime).Abs() > fulcioCertLifetimeTolerance {
+		errs = append(errs, verificationFailure(host, reasonCertLifetime, fmt.Errorf("certificate is valid for %s, want %s", lifetime, fulcioCertLifetime)))
+	}
+	if err := checkTimeSkew("certificate validity start", leaf.NotBefore, time.Now()); err != nil {
+		errs = append(errs, verificationFailure(host, reasonCertLifetime, err))
+	}
+	return errors.Join(errs...)
+}
+
+// Fulcio issuer types whose certificates the write prober knows the shape of.
+const (
+	identityTypeEmail          = "email"
+	identityTypeGitHubWorkflow = "github-workflow"
+	identityTypeKubernetes     = "kubernetes"
+	identityTypeSPIFFE         = "spiffe"
+	identityTypeURI            = "uri"
+)
+
+var identityTypes = []string{identityTypeEmail, identityTypeGitHubWorkflow, identityTypeKubernetes, identityTypeSPIFFE, identityTypeURI}
+
+// checkIdentityShape checks that a certificate issued for a token from an
+// issuer of the given Fulcio type has the SAN and extensions Fulcio derives
+// from the claims for that type. Certificates for other types are not
+// checked beyond verifyFulcioCertificate.
+func checkIdentityShape(host string, leaf *x509.Certificate, identityType string, claims *tokenClaims) error {
+	var errs []error
+	var uris []string
+	for _, u := range leaf.URIs {
+		uris = append(uris, u.String())
+	}
+	checkURI := func(want string) {
+		if !slices.Equal(uris, []string{want}) {
+			errs = append(errs, verificationFailure(host, reasonSANMismatch, fmt.Errorf("got URI SANs %v, want %q", uris, want)))
+		}
+	}
+
+	san, _ := expectedSAN(identityType, claims)
+	switch identityType {
+	case identityTypeEmail:
+		if !slices.Equal(leaf.EmailAddresses, []string{san}) {
+			errs = append(errs, verificationFailure(host, reasonSANMismatch, fmt.Errorf("got email SANs %v, want %q", leaf.EmailAddresses, san)))
+		}
+	case identityTypeGitHubWorkflow:
+		signer := san
+		checkURI(signer)
+		ext, err := certificate.ParseExtensions(leaf.Extensions)
+		if err != nil {
+			errs = append(errs, verificationFailure(host, reasonExtensionMismatch, fmt.Errorf("parsing Fulcio extensions: %w", err)))
+			break
+		}
+		for _, e := range []struct{ name, got, want string }{
+			{"build signer URI", ext.BuildSignerURI, signer},
+			{"source repository URI", ext.SourceRepositoryURI, "https://github.com/" + claims.Repository},
+			{"source repository digest", ext.SourceRepositoryDigest, claims.SHA},
+			{"build trigger", ext.BuildTrigger, claims.EventName},
+			{"GitHub workflow trigger", ext.GithubWorkflowTrigger, claims.EventName},
+			{"GitHub workflow SHA", ext.GithubWorkflowSHA, claims.SHA},
+			{"GitHub workflow repository", ext.GithubWorkflowRepository, claims.Repository},
+		} {
+			if e.got != e.want {
+				errs = append(errs, verificationFailure(host, reasonExtensionMismatch, fmt.Errorf("%s: got %q, want %q", e.name, e.got, e.want)))
+			}
+		}
+	case identityTypeKubernetes, identityTypeSPIFFE, identityTypeURI:
+		checkURI(san)
+	}
+	return errors.Join(errs...)
+}
+
+// expectedSAN returns the subject alternative name Fulcio derives from the
+// claims of a token of identityType. If the type is not known, it is inferred
+// from the claims that identify it reliably: an email, a GitHub workflow or a
+// Kubernetes service account. Otherwise, as for the tokens of other CI
+// providers or of username issuers, whose SANs Fulcio builds from
+// issuer-specific templates, ok is false.
+func expectedSAN(identityType string, claims *tokenClaims) (san string, ok bool) {
+	if identityType == "" {
+		switch {
+		case claims.Email != "":
+			identityType = identityTypeEmail
+		case claims.JobWorkflowRef != "":
+			identityType = identityTypeGitHubWorkflow
+		case claims.Kubernetes.Namespace != "":
+			identityType = identityTypeKubernetes
+		default:
+			return "", false
+		}
+	}
+	switch identityType {
+	case identityTypeEmail:
+		return claims.Email, true
+	case identityTypeGitHubWorkflow:
+		return "https://github.com/" + claims.JobWorkflowRef, true
+	case identityTypeKubernetes:
+		return fmt.Sprintf("https://kubernetes.io/namespaces/%s/serviceaccounts/%s", claims.Kubernetes.Namespace, claims.Kubernetes.ServiceAccount.Name), true
+	default:
+		return claims.Subject, true
+	}
+}
+
+// checkSAN checks the subject alternative names of a certificate against the
+// token of identityType it was issued for: the single SAN must match
+// --identity-san-regexp, and be the one Fulcio derives from the token's
+// claims if expectedSAN knows it.
+func checkSAN(sans []string, identityType string, claims *tokenClaims) error {
+	if len(sans) != 1 {
+		return fmt.Errorf("got %d subject alternative names, want 1", len(sans))
+	}
+	if want, ok := expectedSAN(identityType, claims); ok && sans[0] != want {
+		return fmt.Errorf("got %q, want %q", sans[0], want)
+	}
+	re, err := regexp.Compile(identitySANRegexp)
+	if err != nil {
+		return fmt.Errorf("compiling identity SAN regexp: %w", err)
+	}
+	if !re.MatchString(sans[0]) {
+		return fmt.Errorf("%q does not match %q", sans[0], identitySANRegexp)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sigstore/fulcio/pkg/certificate"
+	"github.com/sigstore/sigstore-go/pkg/root"
+)
+
+func TestCheckIdentityShape(t *testing.T) {
+	github := &tokenClaims{
+		Issuer:         "https://token.actions.githubusercontent.com",
+		Subject:        "repo:sigstore/example:ref:refs/heads/main",
+		JobWorkflowRef: "sigstore/example/.github/workflows/release.yml@refs/heads/main",
+		Repository:     "sigstore/example",
+		SHA:            "0123456789abcdef0123456789abcdef01234567",
+		EventName:      "push",
+	}
+	githubSigner := "https://github.com/sigstore/example/.github/workflows/release.yml@refs/heads/main"
+	githubExtensions := certificate.Extensions{
+		Issuer:                   github.Issuer,
+		BuildSignerURI:           githubSigner,
+		SourceRepositoryURI:      "https://github.com/sigstore/example",
+		SourceRepositoryDigest:   github.SHA,
+		BuildTrigger:             github.EventName,
+		GithubWorkflowTrigger:    github.EventName,
+		GithubWorkflowSHA:        github.SHA,
+		GithubWorkflowRepository: github.Repository,
+	}
+	wrongDigest := githubExtensions
+	wrongDigest.SourceRepositoryDigest = "fedcba9876543210fedcba9876543210fedcba98"
+
+	kubernetes := &tokenClaims{Issuer: "https://kubernetes.default.svc", Subject: "system:serviceaccount:prober:sigstore-prober"}
+	kubernetes.Kubernetes.Namespace = "prober"
+	kubernetes.Kubernetes.ServiceAccount.Name = "sigstore-prober"
+	kubernetesSAN := "https://kubernetes.io/namespaces/prober/serviceaccounts/sigstore-prober"
+
+	spiffe := &tokenClaims{Issuer: "https://spire.example.com", Subject: "spiffe://example.com/prober"}
+
+	email := &tokenClaims{Issuer: "https://oauth2.sigstore.dev/auth", Subject: "1234", Email: "prober@example.com"}
+
+	tests := []struct {
+		name         string
+		identityType string
+		claims       *tokenClaims
+		uris         []string
+		emails       []string
+		extensions   *certificate.Extensions
+		wantErr      bool
+	}{
+		{name: "github workflow", identityType: identityTypeGitHubWorkflow, claims: github, uris: []string{githubSigner}, extensions: &githubExtensions},
+		{name: "github workflow, SAN from subject", identityType: identityTypeGitHubWorkflow, claims: github, uris: []string{github.Subject}, extensions: &githubExtensions, wantErr: true},
+		{name: "github workflow, wrong repository digest", identityType: identityTypeGitHubWorkflow, claims: github, uris: []string{githubSigner}, extensions: &wrongDigest, wantErr: true},
+		{name: "github workflow, issuer extension only", identityType: identityTypeGitHubWorkflow, claims: github, uris: []string{githubSigner}, extensions: &certificate.Extensions{Issuer: github.Issuer}, wantErr: true},
+		{name: "kubernetes", identityType: identityTypeKubernetes, claims: kubernetes, uris: []string{kubernetesSAN}},
+		{name: "kubernetes, SAN from subject", identityType: identityTypeKubernetes, claims: kubernetes, uris: []string{kubernetes.Subject}, wantErr: true},
+		{name: "kubernetes, other namespace", identityType: identityTypeKubernetes, claims: kubernetes, uris: []string{"https://kubernetes.io/namespaces/default/serviceaccounts/sigstore-prober"}, wantErr: true},
+		{name: "spiffe", identityType: identityTypeSPIFFE, claims: spiffe, uris: []string{spiffe.Subject}},
+		{name: "spiffe, other ID", identityType: identityTypeSPIFFE, claims: spiffe, uris: []string{"spiffe://example.com/other"}, wantErr: true},
+		{name: "spiffe, extra URI", identityType: identityTypeSPIFFE, claims: spiffe, uris: []string{spiffe.Subject, "spiffe://example.com/other"}, wantErr: true},
+		{name: "spiffe, email SAN", identityType: identityTypeSPIFFE, claims: spiffe, emails: []string{"prober@example.com"}, wantErr: true},
+		{name: "email", identityType: identityTypeEmail, claims: email, emails: []string{email.Email}},
+		{name: "email, other address", identityType: identityTypeEmail, claims: email, emails: []string{"other@example.com"}, wantErr: true},
+		{name: "untyped", claims: spiffe, uris: []string{"https://example.com/anything"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaf := &x509.Certificate{EmailAddresses: tt.emails}
+			for _, u := range tt.uris {
+				parsed, err := url.Parse(u)
+				if err != nil {
+					t.Fatalf("parsing URI SAN: %v", err)
+				}
+				leaf.URIs = append(leaf.URIs, parsed)
+			}
+			if tt.extensions != nil {
+				extensions, err := tt.extensions.Render()
+				if err != nil {
+					t.Fatalf("rendering extensions: %v", err)
+				}
+				leaf.Extensions = extensions
+			}
+
+			err := checkIdentityShape("https://fulcio.example.com", leaf, tt.identityType, tt.claims)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkIdentityShape() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// gitlabClaims are the claims of a GitLab CI token, whose SAN Fulcio builds
+// from a CI provider template rather than from sub.
+var gitlabClaims = &tokenClaims{
+	Issuer:  "https://gitlab.com",
+	Subject: "project_path:sigstore/example:ref_type:branch:ref:main",
+}
+
+const gitlabSAN = "https://gitlab.com/sigstore/example//.gitlab-ci.yml@refs/heads/main"
+
+func TestCheckSAN(t *testing.T) {
+	oldRegexp := identitySANRegexp
+	t.Cleanup(func() { identitySANRegexp = oldRegexp })
+
+	email := &tokenClaims{Issuer: "https://oauth2.sigstore.dev/auth", Subject: "1234", Email: "prober@example.com"}
+	github := &tokenClaims{Issuer: "https://token.actions.githubusercontent.com", Subject: "repo:sigstore/example:ref:refs/heads/main", JobWorkflowRef: "sigstore/example/.github/workflows/release.yml@refs/heads/main"}
+	spiffe := &tokenClaims{Issuer: "https://spire.example.com", Subject: "spiffe://example.com/prober"}
+
+	tests := []struct {
+		name         string
+		identityType string
+		claims       *tokenClaims
+		sans         []string
+		regexp       string
+		wantErr      bool
+	}{
+		{name: "email", identityType: identityTypeEmail, claims: email, sans: []string{email.Email}},
+		{name: "email, other address", identityType: identityTypeEmail, claims: email, sans: []string{"other@example.com"}, wantErr: true},
+		{name: "untyped email", claims: email, sans: []string{email.Email}},
+		{name: "untyped email, other address", claims: email, sans: []string{"other@example.com"}, wantErr: true},
+		{name: "untyped github workflow", claims: github, sans: []string{"https://github.com/" + github.JobWorkflowRef}},
+		{name: "untyped github workflow, SAN from subject", claims: github, sans: []string{github.Subject}, wantErr: true},
+		{name: "untyped ci provider", claims: gitlabClaims, sans: []string{gitlabSAN}},
+		{name: "untyped ci provider, regexp matches", claims: gitlabClaims, sans: []string{gitlabSAN}, regexp: "^https://gitlab.com/sigstore/"},
+		{name: "untyped ci provider, regexp does not match", claims: gitlabClaims, sans: []string{gitlabSAN}, regexp: "^https://github.com/", wantErr: true},
+		{name: "uri", identityType: identityTypeURI, claims: spiffe, sans: []string{spiffe.Subject}},
+		{name: "uri, other subject", identityType: identityTypeURI, claims: spiffe, sans: []string{"spiffe://example.com/other"}, wantErr: true},
+		{name: "no SAN", claims: gitlabClaims, wantErr: true},
+		{name: "two SANs", claims: gitlabClaims, sans: []string{gitlabSAN, gitlabSAN}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identitySANRegexp = tt.regexp
+			if identitySANRegexp == "" {
+				identitySANRegexp = ".+"
+			}
+			if err := checkSAN(tt.sans, tt.identityType, tt.claims); (err != nil) != tt.wantErr {
+				t.Errorf("checkSAN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testCA is a certificate authority that issues certificates shaped like
+// Fulcio's for tests.
+type testCA struct {
+	ca  *root.FulcioCertificateAuthority
+	key crypto.Signer
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sigstore-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("creating CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing CA certificate: %v", err)
+	}
+	return &testCA{ca: &root.FulcioCertificateAuthority{Root: cert}, key: key}
+}
+
+// issue returns a code signing certificate for key valid for
+// fulcioCertLifetime from now, issued to the token of issuer with the given
+// SANs, after modify changes its template.
+func (c *testCA) issue(t *testing.T, key crypto.PublicKey, issuer string, emails, uris []string, modify func(*x509.Certificate)) *x509.Certificate {
+	t.Helper()
+	extensions, err := certificate.Extensions{Issuer: issuer}.Render()
+	if err != nil {
+		t.Fatalf("rendering extensions: %v", err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       now,
+		NotAfter:        now.Add(fulcioCertLifetime),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		EmailAddresses:  emails,
+		ExtraExtensions: extensions,
+	}
+	for _, u := range uris {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			t.Fatalf("parsing URI SAN: %v", err)
+		}
+		template.URIs = append(template.URIs, parsed)
+	}
+	if modify != nil {
+		modify(template)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.ca.Root, key, c.key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+	return cert
+}
+
+func TestVerifyFulcioCertificate(t *testing.T) {
+	oldLifetime, oldClockSkew, oldRegexp := fulcioCertLifetime, clockSkew, identitySANRegexp
+	fulcioCertLifetime, clockSkew, identitySANRegexp = 10*time.Minute, time.Minute, ".+"
+	t.Cleanup(func() {
+		fulcioCertLifetime, clockSkew, identitySANRegexp = oldLifetime, oldClockSkew, oldRegexp
+	})
+
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	email := &tokenClaims{Issuer: "https://accounts.example.com", Subject: "1234", Email: "prober@example.com"}
+	dex := &tokenClaims{Issuer: "https://oauth2.sigstore.dev/auth", Subject: "1234", Email: "prober@example.com"}
+	dex.FederatedClaims.ConnectorID = "https://github.com/login/oauth"
+
+	tests := []struct {
+		name       string
+		cert       *x509.Certificate
+		claims     *tokenClaims
+		wantReason string
+	}{
+		{name: "email", cert: ca.issue(t, key.Public(), email.Issuer, []string{email.Email}, nil, nil), claims: email},
+		{name: "email, other address", cert: ca.issue(t, key.Public(), email.Issuer, []string{"other@example.com"}, nil, nil), claims: email, wantReason: reasonSANMismatch},
+		{name: "untyped ci provider", cert: ca.issue(t, key.Public(), gitlabClaims.Issuer, nil, []string{gitlabSAN}, nil), claims: gitlabClaims},
+		{name: "untyped ci provider, two SANs", cert: ca.issue(t, key.Public(), gitlabClaims.Issuer, []string{"prober@example.com"}, []string{gitlabSAN}, nil), claims: gitlabClaims, wantReason: reasonSANMismatch},
+		{name: "federated issuer from connector", cert: ca.issue(t, key.Public(), dex.FederatedClaims.ConnectorID, []string{dex.Email}, nil, nil), claims: dex},
+		{name: "federated issuer from token", cert: ca.issue(t, key.Public(), dex.Issuer, []string{dex.Email}, nil, nil), claims: dex},
+		{name: "other issuer", cert: ca.issue(t, key.Public(), "https://other.example.com", []string{email.Email}, nil, nil), claims: email, wantReason: reasonIssuerMismatch},
+		{name: "other CA", cert: otherCA.issue(t, key.Public(), email.Issuer, []string{email.Email}, nil, nil), claims: email, wantReason: reasonChainInvalid},
+		{name: "other key", cert: ca.issue(t, otherKey.Public(), email.Issuer, []string{email.Email}, nil, nil), claims: email, wantReason: reasonPublicKeyMismatch},
+		{name: "certificate signing", cert: ca.issue(t, key.Public(), email.Issuer, []string{email.Email}, nil, func(c *x509.Certificate) {
+			c.KeyUsage |= x509.KeyUsageCertSign
+		}), claims: email, wantReason: reasonKeyUsage},
+		{name: "server authentication", cert: ca.issue(t, key.Public(), email.Issuer, []string{email.Email}, nil, func(c *x509.Certificate) {
+			c.ExtKeyUsage = append(c.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
+		}), claims: email, wantReason: reasonKeyUsage},
+		{name: "long lifetime", cert: ca.issue(t, key.Public(), email.Issuer, []string{email.Email}, nil, func(c *x509.Certificate) {
+			c.NotAfter = c.NotBefore.Add(time.Hour)
+		}), claims: email, wantReason: reasonCertLifetime},
+		{name: "issued in the past", cert: ca.issue(t, key.Public(), email.Issuer, []string{email.Email}, nil, func(c *x509.Certificate) {
+			c.NotBefore = c.NotBefore.Add(-10 * time.Minute)
+			c.NotAfter = c.NotAfter.Add(-10 * time.Minute)
+		}), claims: email, wantReason: reasonCertLifetime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyFulcioCertificate("https://fulcio.example.com", tt.cert, ca.ca, key.Public(), "", tt.claims)
+			checkReason(t, err, tt.wantReason)
+		})
+	}
+}
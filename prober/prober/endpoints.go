@@ -34,7 +34,7 @@ type ReadProberCheck struct {
 // FYI: shard-specific reads are computed in determineShardCoverage
 var ShardlessRekorEndpoints = []ReadProberCheck{
 	{
-		Endpoint: "/api/v1/log/publicKey",
+		Endpoint: rekorPublicKeyEndpoint,
 		Method:   GET,
 		Accept:   "application/x-pem-file",
 	}, {
@@ -60,14 +60,14 @@ var RekorV2ReadEndpoints = []ReadProberCheck{
 
 var FulcioEndpoints = []ReadProberCheck{
 	{
-		Endpoint: "/api/v1/rootCert",
+		Endpoint: fulcioRootCertEndpoint,
 		Method:   GET,
 		Accept:   "application/pem-certificate-chain",
 	}, {
-		Endpoint: "/api/v2/configuration",
+		Endpoint: fulcioConfigurationEndpoint,
 		Method:   GET,
 	}, {
-		Endpoint: "/api/v2/trustBundle",
+		Endpoint: fulcioTrustBundleEndpoint,
 		Method:   GET,
 	},
 }
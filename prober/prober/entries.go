@@ -0,0 +1,241 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/go-openapi/swag/conv"
+	common "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protodsse "github.com/sigstore/protobuf-specs/gen/pb-go/dsse"
+	"github.com/sigstore/rekor-tiles/v2/pkg/generated/protobuf"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/rekor/pkg/types"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature/dsse"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	// Registers the entry kinds the Rekor v1 write prober can submit
+	_ "github.com/sigstore/rekor/pkg/types/dsse/v0.0.1"
+	_ "github.com/sigstore/rekor/pkg/types/intoto/v0.0.2"
+	_ "github.com/sigstore/rekor/pkg/types/rekord/v0.0.1"
+)
+
+// Rekor v1 entry kinds for --rekor-v1-entry-kinds.
+const (
+	rekorV1KindHashedRekord = "hashedrekord"
+	rekorV1KindRekord       = "rekord"
+	rekorV1KindIntoto       = "intoto"
+	rekorV1KindDSSE         = "dsse"
+)
+
+// rekorV1KindVersions maps the entry kinds the Rekor v1 write prober can
+// submit to the version of the kind it submits.
+var rekorV1KindVersions = map[string]string{
+	rekorV1KindHashedRekord: "0.0.1",
+	rekorV1KindRekord:       "0.0.1",
+	rekorV1KindIntoto:       "0.0.2",
+	rekorV1KindDSSE:         "0.0.1",
+}
+
+// Rekor v2 entry kinds for --rekor-v2-entry-kinds.
+const (
+	rekorV2KindHashedRekord = "hashedrekord"
+	rekorV2KindDSSE         = "dsse"
+)
+
+// rekorV2KindVersions maps the entry kinds the Rekor v2 write prober can
+// submit to the version of the kind Rekor v2 logs them as.
+var rekorV2KindVersions = map[string]string{
+	rekorV2KindHashedRekord: "0.0.2",
+	rekorV2KindDSSE:         "0.0.2",
+}
+
+// rekorV1NewEntry builds an entry of kind for a new artifact, signed by key
+// and verified by cert if set, or the public key otherwise. It returns the
+// entry to submit and the implementation of its type, which computes the
+// canonicalized body and index keys Rekor is expected to store. Entries other
+// than hashedrekord are built and validated by Rekor's own type
+// implementations, as rekor-cli does.
+func rekorV1NewEntry(ctx context.Context, kind string, cert *x509.Certificate, key *signingKey) (models.ProposedEntry, types.EntryImpl, error) {
+	if kind == rekorV1KindHashedRekord {
+		entry, err := rekorV1EntryRequest(cert, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &models.Hashedrekord{APIVersion: conv.Pointer(entry.APIVersion()), Spec: entry.HashedRekordObj}, entry, nil
+	}
+
+	verifier, err := rekorV1VerifierPEM(cert, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, err := key.legacySignerVerifier()
+	if err != nil {
+		return nil, nil, err
+	}
+	artifact := []byte(time.Now().String())
+	props := types.ArtifactProperties{PublicKeyBytes: [][]byte{verifier}}
+	switch kind {
+	case rekorV1KindRekord:
+		sig, err := signer.SignMessage(bytes.NewReader(artifact))
+		if err != nil {
+			return nil, nil, fmt.Errorf("sign message: %w", err)
+		}
+		props.ArtifactBytes = artifact
+		props.SignatureBytes = sig
+		props.PKIFormat = "x509"
+	case rekorV1KindIntoto, rekorV1KindDSSE:
+		predicate, err := attestationPredicate()
+		if err != nil {
+			return nil, nil, err
+		}
+		digest := sha256.Sum256(artifact)
+		statement, err := intotoStatement("sha256", hex.EncodeToString(digest[:]), slsaProvenancePredicateType, predicate)
+		if err != nil {
+			return nil, nil, err
+		}
+		envelope, err := dsse.WrapSigner(signer, intotoPayloadType).SignMessage(bytes.NewReader(statement))
+		if err != nil {
+			return nil, nil, fmt.Errorf("signing envelope: %w", err)
+		}
+		props.ArtifactBytes = envelope
+	default:
+		return nil, nil, fmt.Errorf("unsupported entry kind %s", kind)
+	}
+
+	proposed, err := types.NewProposedEntry(ctx, kind, rekorV1KindVersions[kind], props)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, err := types.CreateVersionedEntry(proposed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proposed, entry, nil
+}
+
+// rekorV1VerifierPEM returns the PEM-encoded verifier of a Rekor v1 entry:
+// cert if set, or the public key of key otherwise.
+func rekorV1VerifierPEM(cert *x509.Certificate, key *signingKey) ([]byte, error) {
+	if cert != nil {
+		certPEM, err := cryptoutils.MarshalCertificateToPEM(cert)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling certificate: %w", err)
+		}
+		return certPEM, nil
+	}
+	pubKeyPEM, err := cryptoutils.MarshalPublicKeyToPEM(key.Public())
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling public key: %w", err)
+	}
+	return pubKeyPEM, nil
+}
+
+// rekorV2NewEntry builds a request to add an entry of kind for a new artifact,
+// signed by key and verified by cert if set, or the public key otherwise.
+// DSSE entries log an envelope of an in-toto statement about the artifact.
+func rekorV2NewEntry(kind string, cert *x509.Certificate, key *signingKey) (*protobuf.CreateEntryRequest, error) {
+	verifier, err := rekorV2Verifier(cert, key)
+	if err != nil {
+		return nil, err
+	}
+	artifact := []byte(time.Now().String())
+
+	switch kind {
+	case rekorV2KindHashedRekord:
+		sig, err := key.signArtifact(artifact)
+		if err != nil {
+			return nil, err
+		}
+		return &protobuf.CreateEntryRequest{
+			Spec: &protobuf.CreateEntryRequest_HashedRekordRequestV002{
+				HashedRekordRequestV002: &protobuf.HashedRekordRequestV002{
+					Signature: &protobuf.Signature{
+						Content:  sig,
+						Verifier: verifier,
+					},
+					Digest: key.digest(artifact),
+				},
+			},
+		}, nil
+	case rekorV2KindDSSE:
+		predicate, err := attestationPredicate()
+		if err != nil {
+			return nil, err
+		}
+		digest := sha256.Sum256(artifact)
+		statement, err := intotoStatement("sha256", hex.EncodeToString(digest[:]), slsaProvenancePredicateType, predicate)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := key.envelopeSigner()
+		if err != nil {
+			return nil, err
+		}
+		envelopeJSON, err := dsse.WrapSigner(signer, intotoPayloadType).SignMessage(bytes.NewReader(statement))
+		if err != nil {
+			return nil, fmt.Errorf("signing envelope: %w", err)
+		}
+		// the JSON encoding of a DSSE envelope is also its protobuf JSON
+		// encoding
+		envelope := &protodsse.Envelope{}
+		if err := protojson.Unmarshal(envelopeJSON, envelope); err != nil {
+			return nil, fmt.Errorf("parsing envelope: %w", err)
+		}
+		return &protobuf.CreateEntryRequest{
+			Spec: &protobuf.CreateEntryRequest_DsseRequestV002{
+				DsseRequestV002: &protobuf.DSSERequestV002{
+					Envelope:  envelope,
+					Verifiers: []*protobuf.Verifier{verifier},
+				},
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported entry kind %s", kind)
+	}
+}
+
+// rekorV2Verifier returns the verifier of a Rekor v2 entry: cert if set, or
+// the public key of key otherwise.
+func rekorV2Verifier(cert *x509.Certificate, key *signingKey) (*protobuf.Verifier, error) {
+	verifier := &protobuf.Verifier{
+		KeyDetails: key.details.GetSignatureAlgorithm(),
+	}
+	if cert != nil {
+		verifier.Verifier = &protobuf.Verifier_X509Certificate{
+			X509Certificate: &common.X509Certificate{
+				RawBytes: cert.Raw,
+			},
+		}
+		return verifier, nil
+	}
+	pubBytes, err := cryptoutils.MarshalPublicKeyToDER(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	verifier.Verifier = &protobuf.Verifier_PublicKey{
+		PublicKey: &protobuf.PublicKey{
+			RawBytes: pubBytes,
+		},
+	}
+	return verifier, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/google/certificate-transparency-go/x509util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+const (
+	// eraNone is the era label for a service a bundle does not depend on,
+	// such as the TSA for a bundle without signed timestamps.
+	eraNone = "none"
+	// eraUnknown is the era label for a service that could not be
+	// identified from the bundle.
+	eraUnknown = "unknown"
+)
+
+// bundleEra identifies the keys a bundle depends on, so that a failure to
+// verify it after a trusted root update can be attributed to the Fulcio CA,
+// Rekor log, CT log or TSA that was rotated out. Log IDs are hex encoded as
+// in the key IDs sigstore-go derives from the trusted root.
+type bundleEra struct {
+	// FulcioCA is the authority key ID of the signing certificate, that
+	// is the subject key ID of the issuing Fulcio CA.
+	FulcioCA string
+	RekorLog string
+	CTLog    string
+	// TSA is the serial number of the certificate that signed the
+	// first timestamp.
+	TSA string
+}
+
+func (e bundleEra) labelsFor(bundleName string) prometheus.Labels {
+	return prometheus.Labels{
+		bundleLabel:   bundleName,
+		fulcioCALabel: e.FulcioCA,
+		rekorLogLabel: e.RekorLog,
+		ctLogLabel:    e.CTLog,
+		tsaLabel:      e.TSA,
+	}
+}
+
+// historicalBundlesVerify re-verifies every bundle in the corpus directory
+// offline against the current trusted root, and reports the outcome for
+// each bundle along with the era it was signed in. Bundles that no longer
+// verify after a trusted root update point at the key that was removed.
+func historicalBundlesVerify(trustedRoot *root.TrustedRoot) error {
+	paths, err := filepath.Glob(filepath.Join(bundleCorpusDir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("listing bundle corpus: %w", err)
+	}
+	// drop bundles that were removed from the corpus since the last cycle
+	historicalBundleVerified.Reset()
+
+	var errs []error
+	for _, path := range paths {
+		name := filepath.Base(path)
+		b, err := bundle.LoadJSONFromPath(path)
+		if err != nil {
+			historicalBundleVerified.With(bundleEra{FulcioCA: eraUnknown, RekorLog: eraUnknown, CTLog: eraUnknown, TSA: eraUnknown}.labelsFor(name)).Set(0)
+			errs = append(errs, fmt.Errorf("loading %s: %w", name, err))
+			continue
+		}
+		era := bundleEraOf(b)
+		err = verifyHistoricalBundle(b, trustedRoot)
+		verified := 1.0
+		if err != nil {
+			verified = 0
+			errs = append(errs, fmt.Errorf("verifying %s (fulcio CA %s, rekor log %s, CT log %s, TSA %s): %w", name, era.FulcioCA, era.RekorLog, era.CTLog, era.TSA, err))
+		}
+		historicalBundleVerified.With(era.labelsFor(name)).Set(verified)
+	}
+	return errors.Join(errs...)
+}
+
+// verifyHistoricalBundle verifies a stored bundle without its artifact or
+// signer identity, which are not kept in the corpus. Signatures over a
+// message digest are still checked against the digest in the bundle.
+func verifyHistoricalBundle(b *bundle.Bundle, trustedRoot *root.TrustedRoot) error {
+	verifierOpts := []verify.VerifierOption{verify.WithObserverTimestamps(1)}
+	if vc, err := b.VerificationContent(); err == nil && vc.Certificate() != nil {
+		verifierOpts = append(verifierOpts, verify.WithSignedCertificateTimestamps(1))
+	}
+	if entries, err := b.TlogEntries(); err == nil && len(entries) > 0 {
+		verifierOpts = append(verifierOpts, verify.WithTransparencyLog(1))
+	}
+	verifier, err := verify.NewVerifier(trustedRoot, verifierOpts...)
+	if err != nil {
+		return fmt.Errorf("creating verifier: %w", err)
+	}
+
+	artifactPolicy := verify.WithoutArtifactUnsafe()
+	if sc, err := b.SignatureContent(); err == nil && sc.MessageSignatureContent() != nil {
+		msg := sc.MessageSignatureContent()
+		artifactPolicy = verify.WithArtifactDigest(msg.DigestAlgorithm(), msg.Digest())
+	}
+	_, err = verifier.Verify(b, verify.NewPolicy(artifactPolicy, verify.WithoutIdentitiesUnsafe()))
+	return err
+}
+
+// bundleEraOf extracts the era of a bundle from its verification material.
+func bundleEraOf(b *bundle.Bundle) bundleEra {
+	era := bundleEra{FulcioCA: eraNone, RekorLog: eraNone, CTLog: eraNone, TSA: eraNone}
+	if vc, err := b.VerificationContent(); err == nil {
+		if cert := vc.Certificate(); cert != nil {
+			era.FulcioCA = hex.EncodeToString(cert.AuthorityKeyId)
+			if scts, err := x509util.ParseSCTsFromCertificate(cert.Raw); err == nil && len(scts) > 0 {
+				era.CTLog = hex.EncodeToString(scts[0].LogID.KeyID[:])
+			}
+		}
+	}
+	if entries, err := b.TlogEntries(); err == nil && len(entries) > 0 {
+		era.RekorLog = hex.EncodeToString([]byte(entries[0].LogKeyID()))
+	}
+	if timestamps, err := b.Timestamps(); err == nil && len(timestamps) > 0 {
+		era.TSA = eraUnknown
+		if p7, err := pkcs7.Parse(timestamps[0]); err == nil && len(p7.Signers) > 0 {
+			era.TSA = p7.Signers[0].IssuerAndSerialNumber.SerialNumber.Text(16)
+		}
+	}
+	return era
+}
+
+// saveBundleToCorpus adds a freshly verified bundle to the corpus if no
+// bundle from the same era is stored yet, so that the corpus grows by one
+// bundle each time a key is rotated rather than once per cycle.
+func saveBundleToCorpus(b *bundle.Bundle) error {
+	era := bundleEraOf(b)
+	sum := sha256.Sum256([]byte(strings.Join([]string{era.FulcioCA, era.RekorLog, era.CTLog, era.TSA}, "/")))
+	path := filepath.Join(bundleCorpusDir, "prober-"+hex.EncodeToString(sum[:8])+".json")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	contents, err := b.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("marshalling bundle: %w", err)
+	}
+	if err := os.WriteFile(path, contents, 0o600); err != nil {
+		return fmt.Errorf("writing bundle to corpus: %w", err)
+	}
+	Logger.Infof("saved bundle for a new era to %s", path)
+	return nil
+}
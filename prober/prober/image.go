@@ -0,0 +1,217 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	intotov1 "github.com/in-toto/attestation/go/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sigstore/cosign/v3/pkg/cosign"
+	ociremote "github.com/sigstore/cosign/v3/pkg/oci/remote"
+	"github.com/sigstore/cosign/v3/pkg/types"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/sign"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// slsaProvenancePredicateType is the predicate type cosign uses for
+	// `cosign attest --type slsaprovenance`.
+	slsaProvenancePredicateType = "https://slsa.dev/provenance/v0.2"
+
+	intotoPayloadType = "application/vnd.in-toto+json"
+)
+
+// defaultAttestationPredicate is attested when no --attestation-predicate
+// file is given. It matches prober/attestation.json.
+const defaultAttestationPredicate = `{
+	"buildType": "somebuildtype",
+	"builder": {"id": "foom0YFP0GKOSvP8g=="},
+	"invocation": {"configSource": {}}
+}`
+
+// imageSignAttestEndpoint pushes a random image to an in-process registry,
+// signs it and attaches a SLSA provenance attestation as cosign does with
+// --new-bundle-format, then verifies both through cosign's verification of
+// bundles stored as OCI referrers. It mirrors cosign sign, verify, attest and
+// verify-attestation without requiring docker or the cosign binary.
+func imageSignAttestEndpoint(ctx context.Context, fulcioService root.Service, rekorV1Services, rekorV2Services, tsaServices []root.Service, trustedRoot *root.TrustedRoot) (err error) {
+	rekorService, err := bundleRekorService(rekorV1Services, rekorV2Services, tsaServices)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		imageSignVerifyCounter.With(prometheus.Labels{
+			hostLabel:    rekorService.URL,
+			successLabel: strconv.FormatBool(err == nil),
+		}).Inc()
+	}()
+
+	predicate, err := attestationPredicate()
+	if err != nil {
+		return err
+	}
+
+	reg := httptest.NewServer(registry.New(
+		registry.Logger(log.New(io.Discard, "", 0)),
+		registry.WithReferrersSupport(true),
+	))
+	defer reg.Close()
+
+	ref, err := name.ParseReference(strings.TrimPrefix(reg.URL, "http://") + "/prober/image:latest")
+	if err != nil {
+		return fmt.Errorf("parsing image reference: %w", err)
+	}
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		return fmt.Errorf("generating image: %w", err)
+	}
+	if err := remote.Write(ref, img, remote.WithContext(ctx)); err != nil {
+		return fmt.Errorf("pushing image: %w", err)
+	}
+	h, err := img.Digest()
+	if err != nil {
+		return fmt.Errorf("computing image digest: %w", err)
+	}
+	digest := ref.Context().Digest(h.String())
+
+	signer, err := newBundleSigner(ctx, fulcioService, rekorService, tsaServices)
+	if err != nil {
+		return err
+	}
+	if err := attachImageBundle(digest, types.CosignSignPredicateType, nil, signer); err != nil {
+		return fmt.Errorf("signing image: %w", err)
+	}
+	if err := attachImageBundle(digest, slsaProvenancePredicateType, predicate, signer); err != nil {
+		return fmt.Errorf("attesting image: %w", err)
+	}
+
+	co := &cosign.CheckOpts{
+		NewBundleFormat:     true,
+		TrustedMaterial:     trustedRoot,
+		Identities:          []cosign.Identity{{Issuer: signer.expectedIssuer(), SubjectRegExp: identitySANRegexp}},
+		UseSignedTimestamps: signer.useSignedTimestamps,
+		ClaimVerifier:       cosign.IntotoSubjectClaimVerifier,
+	}
+	atts, _, err := cosign.VerifyImageAttestations(ctx, digest, co)
+	if err != nil {
+		return fmt.Errorf("verifying image bundles: %w", err)
+	}
+	verified := map[string]bool{}
+	for _, att := range atts {
+		payload, err := att.Payload()
+		if err != nil {
+			return fmt.Errorf("reading attestation payload: %w", err)
+		}
+		predicateType, err := statementPredicateType(payload)
+		if err != nil {
+			return err
+		}
+		verified[predicateType] = true
+	}
+	for _, want := range []string{types.CosignSignPredicateType, slsaProvenancePredicateType} {
+		if !verified[want] {
+			return fmt.Errorf("no verified bundle with predicate type %s for %s", want, digest)
+		}
+	}
+	return nil
+}
+
+// attachImageBundle signs an in-toto statement about digest and attaches the
+// resulting bundle to the image as an OCI referrer.
+func attachImageBundle(digest name.Digest, predicateType string, predicate *structpb.Struct, signer *bundleSigner) error {
+	algorithm, hex, ok := strings.Cut(digest.DigestStr(), ":")
+	if !ok {
+		return fmt.Errorf("unable to parse digest %s", digest.DigestStr())
+	}
+	payload, err := intotoStatement(algorithm, hex, predicateType, predicate)
+	if err != nil {
+		return err
+	}
+	pb, err := sign.Bundle(&sign.DSSEData{Data: payload, PayloadType: intotoPayloadType}, signer.keypair, signer.opts)
+	if err != nil {
+		return fmt.Errorf("signing bundle: %w", err)
+	}
+	bundleBytes, err := protojson.Marshal(pb)
+	if err != nil {
+		return fmt.Errorf("marshalling bundle: %w", err)
+	}
+	return ociremote.WriteAttestationNewBundleFormat(digest, bundleBytes, predicateType)
+}
+
+// intotoStatement marshals an in-toto statement with predicate about the
+// subject with the given digest.
+func intotoStatement(algorithm, hex, predicateType string, predicate *structpb.Struct) ([]byte, error) {
+	statement := &intotov1.Statement{
+		Type:          intotov1.StatementTypeUri,
+		Subject:       []*intotov1.ResourceDescriptor{{Digest: map[string]string{algorithm: hex}}},
+		PredicateType: predicateType,
+		Predicate:     predicate,
+	}
+	payload, err := protojson.Marshal(statement)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling statement: %w", err)
+	}
+	return payload, nil
+}
+
+// attestationPredicate loads the predicate to attest from
+// --attestation-predicate, falling back to defaultAttestationPredicate.
+func attestationPredicate() (*structpb.Struct, error) {
+	raw := []byte(defaultAttestationPredicate)
+	if attestationPredicatePath != "" {
+		var err error
+		if raw, err = os.ReadFile(attestationPredicatePath); err != nil {
+			return nil, fmt.Errorf("reading attestation predicate: %w", err)
+		}
+	}
+	predicate := &structpb.Struct{}
+	if err := protojson.Unmarshal(raw, predicate); err != nil {
+		return nil, fmt.Errorf("parsing attestation predicate: %w", err)
+	}
+	return predicate, nil
+}
+
+// statementPredicateType extracts the predicate type of the in-toto
+// statement in a DSSE envelope returned by cosign's verification.
+func statementPredicateType(envelope []byte) (string, error) {
+	var env struct {
+		Payload []byte `json:"payload"`
+	}
+	if err := json.Unmarshal(envelope, &env); err != nil {
+		return "", fmt.Errorf("decoding DSSE envelope: %w", err)
+	}
+	var statement struct {
+		PredicateType string `json:"predicateType"`
+	}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return "", fmt.Errorf("decoding in-toto statement: %w", err)
+	}
+	return statement.PredicateType, nil
+}
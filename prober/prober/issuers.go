@@ -0,0 +1,171 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sigstore/sigstore-go/pkg/root"
+)
+
+const (
+	fulcioConfigurationEndpoint = "/api/v2/configuration"
+	oidcDiscoveryEndpoint       = "/.well-known/openid-configuration"
+)
+
+// oidcIssuersCheckedAt is when the reachability of the configured OIDC
+// issuers was last checked, to check it at most every
+// --oidc-issuer-check-interval.
+var oidcIssuersCheckedAt time.Time
+
+// fulcioConfiguration is the JSON encoding of the response to
+// /api/v2/configuration.
+type fulcioConfiguration struct {
+	Issuers []struct {
+		IssuerURL         string `json:"issuerUrl"`
+		WildcardIssuerURL string `json:"wildcardIssuerUrl"`
+	} `json:"issuers"`
+}
+
+// verifyFulcioIssuers compares the OIDC issuers configured in Fulcio to
+// --expected-oidc-issuers, reporting issuers that were removed or added. With
+// --check-oidc-issuers, it also checks at most every
+// --oidc-issuer-check-interval that the discovery document and keys of every
+// configured issuer can be fetched, as Fulcio needs them to verify tokens.
+// Unreachable issuers are third parties, so they are only reported in
+// oidcIssuerReachable and do not fail the probe. Wildcard issuers, which
+// match many URLs, are only compared to the expected list.
+func verifyFulcioIssuers(fulcioService root.Service, configuration []byte) error {
+	var config fulcioConfiguration
+	if err := json.Unmarshal(configuration, &config); err != nil {
+		return fmt.Errorf("parsing %s: %w", fulcioConfigurationEndpoint, err)
+	}
+	host := fulcioService.URL
+
+	var configured, urls []string
+	for _, issuer := range config.Issuers {
+		if issuer.IssuerURL != "" {
+			configured = append(configured, issuer.IssuerURL)
+			urls = append(urls, issuer.IssuerURL)
+		} else {
+			configured = append(configured, issuer.WildcardIssuerURL)
+		}
+	}
+
+	var errs []error
+	if expectedOIDCIssuers != "" {
+		var expected []string
+		for _, issuer := range strings.Split(expectedOIDCIssuers, ",") {
+			expected = append(expected, strings.TrimSpace(issuer))
+		}
+		for _, issuer := range expected {
+			if !slices.Contains(configured, issuer) {
+				errs = append(errs, verificationFailure(host, reasonIssuerRemoved, fmt.Errorf("expected issuer %s is not configured", issuer)))
+			}
+		}
+		for _, issuer := range configured {
+			if !slices.Contains(expected, issuer) {
+				errs = append(errs, verificationFailure(host, reasonIssuerAdded, fmt.Errorf("issuer %s is configured but not expected", issuer)))
+			}
+		}
+	}
+
+	if checkOIDCIssuers && time.Since(oidcIssuersCheckedAt) >= oidcIssuerCheckInterval {
+		oidcIssuersCheckedAt = time.Now()
+		// drop issuers that were removed from the configuration
+		oidcIssuerReachable.Reset()
+		for _, issuer := range urls {
+			reachable := 1.0
+			if err := checkOIDCIssuer(issuer); err != nil {
+				reachable = 0
+				Logger.Warnf("OIDC issuer %s configured in %s is not reachable: %v", issuer, host, err)
+			}
+			oidcIssuerReachable.With(prometheus.Labels{hostLabel: host, issuerLabel: issuer}).Set(reachable)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkOIDCIssuer fetches the discovery document of an issuer and the key
+// set it points to.
+func checkOIDCIssuer(issuer string) error {
+	body, err := fetchOIDCDocument(strings.TrimSuffix(issuer, "/"), oidcDiscoveryEndpoint)
+	if err != nil {
+		return fmt.Errorf("fetching discovery document: %w", err)
+	}
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.Unmarshal(body, &discovery); err != nil {
+		return fmt.Errorf("parsing discovery document: %w", err)
+	}
+	if discovery.Issuer != issuer {
+		return fmt.Errorf("discovery document is for issuer %q", discovery.Issuer)
+	}
+
+	jwksURL, err := url.Parse(discovery.JWKSURI)
+	if err != nil || jwksURL.Scheme == "" || jwksURL.Host == "" {
+		return fmt.Errorf("invalid jwks_uri %q", discovery.JWKSURI)
+	}
+	body, err = fetchOIDCDocument(jwksURL.Scheme+"://"+jwksURL.Host, jwksURL.RequestURI())
+	if err != nil {
+		return fmt.Errorf("fetching keys: %w", err)
+	}
+	var jwks struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &jwks); err != nil {
+		return fmt.Errorf("parsing keys: %w", err)
+	}
+	if len(jwks.Keys) == 0 {
+		return errors.New("key set is empty")
+	}
+	return nil
+}
+
+// fetchOIDCDocument fetches a document from an OIDC issuer. Issuers are not
+// Sigstore services, so unlike observeRequest the request is not exported to
+// the endpoint latency metrics.
+func fetchOIDCDocument(host, requestURI string) ([]byte, error) {
+	req, err := retryablehttp.NewRequest(http.MethodGet, host+requestURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	setHeaders(req, "", ReadProberCheck{})
+	resp, err := retryableClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error response: status: %s", resp.Status)
+	}
+	return body, nil
+}
@@ -0,0 +1,199 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+const (
+	rekorPublicKeyEndpoint = "/api/v1/log/publicKey"
+	ctGetRootsEndpoint     = "/ct/v1/get-roots"
+	ctGetSTHEndpoint       = "/ct/v1/get-sth"
+)
+
+// rekorV1VerifyPublicKeys compares the public keys served by a Rekor v1
+// instance to the transparency logs in the trusted root. The key of the
+// active shard, activeKey, must be a currently valid log for rekorURL, and
+// the key of every inactive shard, fetched by tree ID, must be a log in the
+// trusted root, or clients cannot verify entries from that shard.
+func rekorV1VerifyPublicKeys(rekorURL string, activeKey []byte, logInfo *LogInfo, trustedRoot *root.TrustedRoot) error {
+	rekorLogs := trustedRoot.RekorLogs()
+	var errs []error
+
+	tlog, err := rekorLogForKey(rekorURL, activeKey, rekorLogs)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("active shard: %w", err))
+	} else {
+		now := time.Now()
+		switch {
+		case tlog.BaseURL != rekorURL:
+			errs = append(errs, verificationFailure(rekorURL, reasonRekorKeyMismatch, fmt.Errorf("active shard key belongs to log %s in the trusted root", tlog.BaseURL)))
+		case now.Before(tlog.ValidityPeriodStart) || !tlog.ValidityPeriodEnd.IsZero() && now.After(tlog.ValidityPeriodEnd):
+			errs = append(errs, verificationFailure(rekorURL, reasonRekorKeyMismatch, errors.New("active shard key is not currently valid in the trusted root")))
+		}
+	}
+
+	if logInfo == nil {
+		return errors.Join(errs...)
+	}
+	for _, shard := range logInfo.InactiveShards {
+		key, err := observeRequest(rekorURL, ReadProberCheck{
+			Endpoint: rekorPublicKeyEndpoint,
+			Method:   GET,
+			Accept:   "application/x-pem-file",
+			Queries:  map[string]string{"treeID": shard.TreeID},
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("fetching key of shard %s: %w", shard.TreeID, err))
+			continue
+		}
+		if _, err := rekorLogForKey(rekorURL, key, rekorLogs); err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", shard.TreeID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rekorLogForKey finds the transparency log in the trusted root whose log ID,
+// the SHA-256 digest of the DER-encoded public key, matches a PEM-encoded key
+// served by Rekor.
+func rekorLogForKey(rekorURL string, keyPEM []byte, rekorLogs map[string]*root.TransparencyLog) (*root.TransparencyLog, error) {
+	key, err := cryptoutils.UnmarshalPEMToPublicKey(keyPEM)
+	if err != nil {
+		return nil, verificationFailure(rekorURL, reasonRekorKeyUnknown, fmt.Errorf("parsing public key: %w", err))
+	}
+	der, err := cryptoutils.MarshalPublicKeyToDER(key)
+	if err != nil {
+		return nil, verificationFailure(rekorURL, reasonRekorKeyUnknown, fmt.Errorf("encoding public key: %w", err))
+	}
+	sum := sha256.Sum256(der)
+	logID := hex.EncodeToString(sum[:])
+	tlog, ok := rekorLogs[logID]
+	if !ok {
+		return nil, verificationFailure(rekorURL, reasonRekorKeyUnknown, fmt.Errorf("no log with ID %s in the trusted root", logID))
+	}
+	if err := cryptoutils.EqualKeys(key, tlog.PublicKey); err != nil {
+		return nil, verificationFailure(rekorURL, reasonRekorKeyMismatch, err)
+	}
+	return tlog, nil
+}
+
+// ctLogsCheckedAt is when the CT logs were last checked, to check them at
+// most every --ct-log-check-interval.
+var ctLogsCheckedAt time.Time
+
+// verifyCTLogKeys checks, at most every --ct-log-check-interval, every
+// currently valid CT log in the trusted root: its signed tree head must
+// verify with the key in the trusted root. The root of the active CA of
+// fulcioService must also be accepted by one of these logs, or Fulcio cannot
+// obtain SCTs. Logs are not required to accept the roots of every CA, as they
+// may serve other Fulcio instances or CAs that are being rotated out.
+func verifyCTLogKeys(fulcioService root.Service, trustedRoot *root.TrustedRoot) error {
+	if time.Since(ctLogsCheckedAt) < ctLogCheckInterval {
+		return nil
+	}
+	ctLogsCheckedAt = time.Now()
+
+	fulcioCA, err := activeFulcioCA(fulcioService, trustedRoot)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var errs []error
+	var checked, accepting []string
+	for logID, ctLog := range trustedRoot.CTLogs() {
+		if now.Before(ctLog.ValidityPeriodStart) || !ctLog.ValidityPeriodEnd.IsZero() && now.After(ctLog.ValidityPeriodEnd) {
+			continue
+		}
+		if err := verifyCTLogSTH(ctLog); err != nil {
+			errs = append(errs, fmt.Errorf("CT log %s (%s): %w", ctLog.BaseURL, logID, err))
+		}
+		accepted, err := ctLogAcceptsRoot(ctLog, fulcioCA.Root.Raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("CT log %s (%s): %w", ctLog.BaseURL, logID, err))
+			continue
+		}
+		checked = append(checked, ctLog.BaseURL)
+		if accepted {
+			accepting = append(accepting, ctLog.BaseURL)
+		}
+	}
+	if len(checked) > 0 && len(accepting) == 0 {
+		sum := sha256.Sum256(fulcioCA.Root.Raw)
+		errs = append(errs, verificationFailure(fulcioService.URL, reasonCTRootMissing, fmt.Errorf("root %x of the active Fulcio CA is not accepted by any of the CT logs %v", sum, checked)))
+	}
+	return errors.Join(errs...)
+}
+
+// verifyCTLogSTH checks the signature on the CT log's signed tree head with
+// the key in the trusted root.
+func verifyCTLogSTH(ctLog *root.TransparencyLog) error {
+	body, err := observeRequest(ctLog.BaseURL, ReadProberCheck{Endpoint: ctGetSTHEndpoint, Method: GET})
+	if err != nil {
+		return fmt.Errorf("fetching signed tree head: %w", err)
+	}
+	var resp ct.GetSTHResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("parsing signed tree head: %w", err)
+	}
+	sth, err := resp.ToSignedTreeHead()
+	if err != nil {
+		return fmt.Errorf("parsing signed tree head: %w", err)
+	}
+	verifier, err := ct.NewSignatureVerifier(ctLog.PublicKey)
+	if err != nil {
+		return fmt.Errorf("loading CT log key: %w", err)
+	}
+	if err := verifier.VerifySTHSignature(*sth); err != nil {
+		return verificationFailure(ctLog.BaseURL, reasonCTKeyMismatch, err)
+	}
+	return nil
+}
+
+// ctLogAcceptsRoot reports whether the CT log accepts certificates chaining
+// to the given root.
+func ctLogAcceptsRoot(ctLog *root.TransparencyLog, fulcioRoot []byte) (bool, error) {
+	body, err := observeRequest(ctLog.BaseURL, ReadProberCheck{Endpoint: ctGetRootsEndpoint, Method: GET})
+	if err != nil {
+		return false, fmt.Errorf("fetching accepted roots: %w", err)
+	}
+	var resp ct.GetRootsResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return false, fmt.Errorf("parsing accepted roots: %w", err)
+	}
+	for _, c := range resp.Certificates {
+		der, err := base64.StdEncoding.DecodeString(c)
+		if err != nil {
+			return false, fmt.Errorf("decoding accepted root: %w", err)
+		}
+		if bytes.Equal(der, fulcioRoot) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
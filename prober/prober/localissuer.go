@@ -0,0 +1,136 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	localIssuerKeysEndpoint = "/keys"
+	// localIssuerTokenLifetime is how long tokens minted by the embedded
+	// issuer are valid for, enough for one cycle of write probes.
+	localIssuerTokenLifetime = 5 * time.Minute
+	// localIssuerExpiredTokenAge is how long before now expired tokens were
+	// issued, so that they are expired beyond the clock skew verifiers allow.
+	localIssuerExpiredTokenAge = time.Hour
+)
+
+// localIssuer is a minimal OIDC issuer embedded in the prober. It serves the
+// discovery document and signing keys under its URL and mints tokens for the
+// write probers, so that a Fulcio configured to trust it can be probed where
+// no workload identity is available. The signing key is generated at startup;
+// Fulcio fetches the new key when it sees an unknown key ID.
+type localIssuer struct {
+	url    string
+	jwk    jose.JSONWebKey
+	signer jose.Signer
+}
+
+func newLocalIssuer(issuerURL string) (*localIssuer, error) {
+	u, err := url.Parse(issuerURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid issuer URL %q", issuerURL)
+	}
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating signing key: %w", err)
+	}
+	jwk := jose.JSONWebKey{Key: priv, Algorithm: string(jose.ES256), Use: "sig"}
+	thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	jwk.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jwk}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return nil, fmt.Errorf("creating signer: %w", err)
+	}
+	return &localIssuer{url: strings.TrimSuffix(issuerURL, "/"), jwk: jwk, signer: signer}, nil
+}
+
+// register serves the discovery document and keys of the issuer on mux, at
+// the path of the issuer URL.
+func (i *localIssuer) register(mux *http.ServeMux) error {
+	u, err := url.Parse(i.url)
+	if err != nil {
+		return err
+	}
+	discovery, err := json.Marshal(map[string]any{
+		"issuer":                                i.url,
+		"jwks_uri":                              i.url + localIssuerKeysEndpoint,
+		"response_types_supported":              []string{"id_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{string(jose.ES256)},
+		"claims_supported":                      []string{"iss", "sub", "aud", "iat", "exp", "email", "email_verified"},
+	})
+	if err != nil {
+		return err
+	}
+	keys, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{i.jwk.Public()}})
+	if err != nil {
+		return err
+	}
+	mux.HandleFunc("GET "+u.Path+oidcDiscoveryEndpoint, serveJSON(discovery))
+	mux.HandleFunc("GET "+u.Path+localIssuerKeysEndpoint, serveJSON(keys))
+	return nil
+}
+
+func serveJSON(body []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}
+}
+
+// token mints a token for --local-oidc-subject with the given audience. The
+// subject is also set as a verified email, as Fulcio requires of tokens from
+// email issuers.
+func (i *localIssuer) token(audience string) (string, error) {
+	return i.mint(audience, time.Now())
+}
+
+// expiredToken mints a token like token, but issued localIssuerExpiredTokenAge
+// ago and long expired, which Fulcio must reject.
+func (i *localIssuer) expiredToken(audience string) (string, error) {
+	return i.mint(audience, time.Now().Add(-localIssuerExpiredTokenAge))
+}
+
+func (i *localIssuer) mint(audience string, now time.Time) (string, error) {
+	return jwt.Signed(i.signer).Claims(jwt.Claims{
+		Issuer:    i.url,
+		Subject:   localIssuerSubject,
+		Audience:  jwt.Audience{audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Expiry:    jwt.NewNumericDate(now.Add(localIssuerTokenLifetime)),
+	}).Claims(map[string]any{
+		"email":          localIssuerSubject,
+		"email_verified": true,
+	}).Serialize()
+}
@@ -0,0 +1,308 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // the TSA must reject SHA-1
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/digitorus/timestamp"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/swag/conv"
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/prometheus/client_golang/prometheus"
+	fulciopb "github.com/sigstore/fulcio/pkg/generated/protobuf"
+	common "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	"github.com/sigstore/rekor-tiles/v2/pkg/generated/protobuf"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// negative probe names used as metric labels
+	rekorV1RejectSignatureProbe       = "rekor_v1_reject_signature"
+	rekorV2RejectKeyDetailsProbe      = "rekor_v2_reject_key_details"
+	fulcioRejectProofProbe            = "fulcio_reject_proof_of_possession"
+	fulcioGrpcRejectProofProbe        = "fulcio_grpc_reject_proof_of_possession"
+	fulcioRejectExpiredTokenProbe     = "fulcio_reject_expired_token"
+	fulcioGrpcRejectExpiredTokenProbe = "fulcio_grpc_reject_expired_token"
+	tsaRejectHashProbe                = "tsa_reject_hash_algorithm"
+
+	// outcomes of negative probes recorded by writeRejectionCounter
+	rejectionOutcomeRejected = "rejected"
+	rejectionOutcomeAccepted = "accepted"
+	rejectionOutcomeError    = "error"
+
+	// negativeProbeKeyAlgorithm is the algorithm of the key negative probes
+	// sign with. The Rekor v2 probe declares it as P-384, so it must not be.
+	negativeProbeKeyAlgorithm = "ecdsa-sha2-256-nistp256"
+)
+
+// errRequestAccepted is returned by negative probes when a service accepted
+// a request it should have rejected.
+var errRequestAccepted = errors.New("invalid request was accepted")
+
+// negativeWriteEndpoints sends invalid requests to the write endpoints and
+// checks that every service rejects them with the expected status: a
+// hashedrekord with a wrong signature to Rekor v1, an entry whose key details
+// do not match its key to Rekor v2, a certificate request with an invalid
+// proof of possession and, if the embedded issuer is enabled, one with an
+// expired token to Fulcio, and a timestamp request with a weak hash to the
+// TSAs. The requests do not depend on Fulcio issuing certificates.
+func negativeWriteEndpoints(ctx context.Context, fulcioGrpcClient fulciopb.CAClient, fulcioGrpcURL string, fulcioService root.Service, rekorV1Services, rekorV2Services, tsaServices []root.Service) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key, err := newSigningKey(negativeProbeKeyAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	errs = append(errs, fulcioRejectProofOfPossession(ctx, key, fulcioGrpcClient, fulcioGrpcURL, fulcioService))
+	if embeddedIssuer != nil {
+		errs = append(errs, fulcioRejectExpiredToken(ctx, key, fulcioGrpcClient, fulcioGrpcURL, fulcioService))
+	}
+	errs = append(errs, rekorV1RejectSignature(key, rekorV1Services))
+	if len(rekorV2Services) > 0 {
+		errs = append(errs, rekorV2RejectKeyDetails(key, rekorV2Services))
+	}
+	errs = append(errs, tsaRejectHash(tsaServices))
+	return errors.Join(errs...)
+}
+
+// fulcioRejectProofOfPossession requests a certificate for key with a proof
+// of possession signed by another key. A Fulcio that issues the certificate
+// would let anyone obtain certificates for keys they do not hold.
+func fulcioRejectProofOfPossession(ctx context.Context, key *signingKey, fulcioGrpcClient fulciopb.CAClient, fulcioGrpcURL string, fulcioService root.Service) error {
+	tok, err := identityToken(ctx)
+	if err != nil {
+		return expectRejection(fulcioRejectProofProbe, fulcioService.URL, func() error { return err })
+	}
+	other, err := newSigningKey(key.name)
+	if err != nil {
+		return err
+	}
+	b, err := certificateRequest(ctx, tok, other)
+	if err != nil {
+		return fmt.Errorf("certificate request: %w", err)
+	}
+	var req SigningCertificateRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		return err
+	}
+	pubBytesPEM, err := cryptoutils.MarshalPublicKeyToPEM(key.Public())
+	if err != nil {
+		return err
+	}
+	req.PublicKeyRequest.PublicKey.Content = string(pubBytesPEM)
+	if b, err = json.Marshal(req); err != nil {
+		return err
+	}
+	return fulcioExpectRejection(ctx, fulcioRejectProofProbe, fulcioGrpcRejectProofProbe, tok, b, fulcioGrpcClient, fulcioGrpcURL, fulcioService)
+}
+
+// fulcioRejectExpiredToken requests a certificate with a valid proof of
+// possession, but a token from the embedded issuer that has long expired.
+func fulcioRejectExpiredToken(ctx context.Context, key *signingKey, fulcioGrpcClient fulciopb.CAClient, fulcioGrpcURL string, fulcioService root.Service) error {
+	tok, err := embeddedIssuer.expiredToken(oidcAudience)
+	if err != nil {
+		return err
+	}
+	b, err := certificateRequest(ctx, tok, key)
+	if err != nil {
+		return fmt.Errorf("certificate request: %w", err)
+	}
+	return fulcioExpectRejection(ctx, fulcioRejectExpiredTokenProbe, fulcioGrpcRejectExpiredTokenProbe, tok, b, fulcioGrpcClient, fulcioGrpcURL, fulcioService)
+}
+
+// fulcioExpectRejection sends a certificate request to the HTTP API and, if
+// enabled, the gRPC API of Fulcio, which must both reject it as an invalid
+// argument.
+func fulcioExpectRejection(ctx context.Context, probe, grpcProbe, tok string, body []byte, fulcioGrpcClient fulciopb.CAClient, fulcioGrpcURL string, fulcioService root.Service) error {
+	var errs []error
+	errs = append(errs, expectRejection(probe, fulcioService.URL, func() error {
+		return expectHTTPStatus(fulcioService.URL, ReadProberCheck{Endpoint: fulcioEndpoint, Method: POST, Body: body}, tok, http.StatusBadRequest)
+	}))
+	if fulcioGrpcClient != nil {
+		errs = append(errs, expectRejection(grpcProbe, "grpc://"+fulcioGrpcURL, func() error {
+			// the HTTP request body is the JSON encoding of the gRPC request
+			var req fulciopb.CreateSigningCertificateRequest
+			if err := protojson.Unmarshal(body, &req); err != nil {
+				return fmt.Errorf("converting certificate request: %w", err)
+			}
+			req.Credentials = &fulciopb.Credentials{
+				Credentials: &fulciopb.Credentials_OidcIdentityToken{OidcIdentityToken: tok},
+			}
+			_, err := fulcioGrpcClient.CreateSigningCertificate(ctx, &req)
+			return expectGrpcCode(err, codes.InvalidArgument)
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// rekorV1RejectSignature submits a hashedrekord whose signature is over
+// another artifact than the digest of the entry.
+func rekorV1RejectSignature(key *signingKey, rekorV1Services []root.Service) error {
+	entry, err := rekorV1EntryRequest(nil, key)
+	if err != nil {
+		return err
+	}
+	sig, err := key.signArtifact([]byte("not " + time.Now().String()))
+	if err != nil {
+		return err
+	}
+	entry.HashedRekordObj.Signature.Content = strfmt.Base64(sig)
+	b, err := json.Marshal(&models.Hashedrekord{APIVersion: conv.Pointer(entry.APIVersion()), Spec: entry.HashedRekordObj})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range rekorV1Services {
+		errs = append(errs, expectRejection(rekorV1RejectSignatureProbe, s.URL, func() error {
+			return expectHTTPStatus(s.URL, ReadProberCheck{Endpoint: rekorEndpoint, Method: POST, Body: b}, "", http.StatusBadRequest)
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// rekorV2RejectKeyDetails submits a hashedrekord signed over a SHA-384 digest
+// by a P-256 key whose key details claim it is a P-384 key.
+func rekorV2RejectKeyDetails(key *signingKey, rekorV2Services []root.Service) error {
+	h := crypto.SHA384.New()
+	h.Write([]byte(time.Now().String()))
+	digest := h.Sum(nil)
+	sig, err := key.Sign(rand.Reader, digest, crypto.SHA384)
+	if err != nil {
+		return err
+	}
+	verifier, err := rekorV2Verifier(nil, key)
+	if err != nil {
+		return err
+	}
+	verifier.KeyDetails = common.PublicKeyDetails_PKIX_ECDSA_P384_SHA_384
+	b, err := protojson.Marshal(&protobuf.CreateEntryRequest{
+		Spec: &protobuf.CreateEntryRequest_HashedRekordRequestV002{
+			HashedRekordRequestV002: &protobuf.HashedRekordRequestV002{
+				Signature: &protobuf.Signature{
+					Content:  sig,
+					Verifier: verifier,
+				},
+				Digest: digest,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range rekorV2Services {
+		errs = append(errs, expectRejection(rekorV2RejectKeyDetailsProbe, s.URL, func() error {
+			return expectHTTPStatus(s.URL, ReadProberCheck{Endpoint: rekorV2Endpoint, Method: POST, Body: b}, "", http.StatusBadRequest)
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// tsaRejectHash requests a timestamp over a SHA-1 digest, which TSAs must
+// reject as a weak hash algorithm.
+func tsaRejectHash(tsaServices []root.Service) error {
+	digest := sha1.Sum([]byte(time.Now().String())) //nolint:gosec // the TSA must reject SHA-1
+	b, err := (&timestamp.Request{
+		HashAlgorithm: crypto.SHA1,
+		HashedMessage: digest[:],
+	}).Marshal()
+	if err != nil {
+		return fmt.Errorf("marshalling the timestamp request: %w", err)
+	}
+	proberCheck := TSAEndpoints[0]
+	proberCheck.Body = b
+	var errs []error
+	for _, s := range tsaServices {
+		errs = append(errs, expectRejection(tsaRejectHashProbe, s.URL, func() error {
+			return expectHTTPStatus(s.URL, proberCheck, "", http.StatusBadRequest)
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// expectRejection runs a negative probe against host and records whether the
+// service rejected the request, accepted it, or the probe failed.
+func expectRejection(probe, host string, check func() error) error {
+	err := check()
+	outcome := rejectionOutcomeRejected
+	switch {
+	case errors.Is(err, errRequestAccepted):
+		outcome = rejectionOutcomeAccepted
+		Logger.Errorf("%s: %s accepted an invalid request: %v", probe, host, err)
+	case err != nil:
+		outcome = rejectionOutcomeError
+	}
+	writeRejectionCounter.With(prometheus.Labels{probeLabel: probe, hostLabel: host, outcomeLabel: outcome}).Inc()
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", probe, host, err)
+	}
+	return nil
+}
+
+// expectHTTPStatus sends a request that host must reject with want. The
+// response is not recorded in the endpoint latency metrics, so that expected
+// errors do not count against the availability of the service.
+func expectHTTPStatus(host string, r ReadProberCheck, token string, want int) error {
+	req, err := retryablehttp.NewRequest(r.Method, host+r.Endpoint, bytes.NewBuffer(r.Body))
+	if err != nil {
+		return err
+	}
+	setHeaders(req, token, r)
+	resp, err := retryableClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	switch {
+	case resp.StatusCode == want:
+		return nil
+	case resp.StatusCode < http.StatusMultipleChoices:
+		return fmt.Errorf("%w with status %s", errRequestAccepted, resp.Status)
+	default:
+		return fmt.Errorf("got status %s, want %d: %s", resp.Status, want, body)
+	}
+}
+
+// expectGrpcCode checks that a gRPC call failed with want.
+func expectGrpcCode(err error, want codes.Code) error {
+	switch {
+	case err == nil:
+		return errRequestAccepted
+	case status.Code(err) == want:
+		return nil
+	default:
+		return fmt.Errorf("got %s, want %s: %w", status.Code(err), want, err)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestExpectHTTPStatus(t *testing.T) {
+	oldClient := retryableClient
+	retryableClient = retryablehttp.NewClient()
+	retryableClient.Logger = nil
+	retryableClient.RetryMax = 0
+	t.Cleanup(func() { retryableClient = oldClient })
+
+	tests := []struct {
+		name         string
+		status       int
+		wantErr      bool
+		wantAccepted bool
+	}{
+		{name: "rejected as expected", status: http.StatusBadRequest},
+		{name: "accepted", status: http.StatusCreated, wantErr: true, wantAccepted: true},
+		{name: "rejected otherwise", status: http.StatusUnauthorized, wantErr: true},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Authorization"); got != "Bearer token" {
+					t.Errorf("got authorization %q, want the bearer token", got)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			t.Cleanup(server.Close)
+
+			err := expectHTTPStatus(server.URL, ReadProberCheck{Endpoint: "/api", Method: POST, Body: []byte("{}")}, "token", http.StatusBadRequest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expectHTTPStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, errRequestAccepted) != tt.wantAccepted {
+				t.Errorf("expectHTTPStatus() error = %v, want accepted %v", err, tt.wantAccepted)
+			}
+		})
+	}
+}
+
+func TestExpectGrpcCode(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantErr      bool
+		wantAccepted bool
+	}{
+		{name: "rejected as expected", err: status.Error(codes.InvalidArgument, "invalid proof")},
+		{name: "accepted", wantErr: true, wantAccepted: true},
+		{name: "rejected otherwise", err: status.Error(codes.Unauthenticated, "invalid token"), wantErr: true},
+		{name: "not a gRPC error", err: errors.New("connection refused"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := expectGrpcCode(tt.err, codes.InvalidArgument)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expectGrpcCode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, errRequestAccepted) != tt.wantAccepted {
+				t.Errorf("expectGrpcCode() error = %v, want accepted %v", err, tt.wantAccepted)
+			}
+		})
+	}
+}
+
+func TestExpectRejection(t *testing.T) {
+	Logger = proberLogger{zap.NewNop().Sugar()}
+	tests := []struct {
+		name        string
+		err         error
+		wantOutcome string
+	}{
+		{name: "rejected", wantOutcome: rejectionOutcomeRejected},
+		{name: "accepted", err: errRequestAccepted, wantOutcome: rejectionOutcomeAccepted},
+		{name: "probe failed", err: errors.New("connection refused"), wantOutcome: rejectionOutcomeError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := "https://" + tt.wantOutcome + ".example.com"
+			err := expectRejection("test_probe", host, func() error { return tt.err })
+			if !errors.Is(err, tt.err) || (err == nil) != (tt.err == nil) {
+				t.Errorf("expectRejection() error = %v, want %v", err, tt.err)
+			}
+			for _, outcome := range []string{rejectionOutcomeRejected, rejectionOutcomeAccepted, rejectionOutcomeError} {
+				want := 0.0
+				if outcome == tt.wantOutcome {
+					want = 1
+				}
+				counter := writeRejectionCounter.With(prometheus.Labels{probeLabel: "test_probe", hostLabel: host, outcomeLabel: outcome})
+				if got := testutil.ToFloat64(counter); got != want {
+					t.Errorf("got %v %s outcomes, want %v", got, outcome, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// tokenClaims holds the claims of an OIDC identity token that the probers
+// compare against issued certificates.
+type tokenClaims struct {
+	Issuer  string `json:"iss"`
+	Subject string `json:"sub"`
+	Email   string `json:"email"`
+	Expiry  int64  `json:"exp"`
+
+	// Dex claims of the upstream issuer the token was federated from
+	FederatedClaims struct {
+		ConnectorID string `json:"connector_id"`
+	} `json:"federated_claims"`
+
+	// GitHub Actions claims
+	JobWorkflowRef string `json:"job_workflow_ref"`
+	Repository     string `json:"repository"`
+	SHA            string `json:"sha"`
+	EventName      string `json:"event_name"`
+
+	// Kubernetes service account claims
+	Kubernetes struct {
+		Namespace      string `json:"namespace"`
+		ServiceAccount struct {
+			Name string `json:"name"`
+		} `json:"serviceaccount"`
+	} `json:"kubernetes.io"`
+}
+
+// parseTokenClaims decodes the claims of a JWT without verifying it. The
+// token is verified by Fulcio, the prober only needs to know what to expect
+// in the certificate.
+func parseTokenClaims(token string) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("decoding token payload: %w", err)
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("parsing token claims: %w", err)
+	}
+	return &claims, nil
+}
+
+// certificateIssuers returns the issuers Fulcio may write into a certificate
+// for the token. For tokens federated by Dex, Fulcio is usually configured
+// to take the issuer from the connector ID rather than from iss, which the
+// prober cannot tell from the outside, so both are accepted.
+func (c *tokenClaims) certificateIssuers() []string {
+	if c.FederatedClaims.ConnectorID != "" {
+		return []string{c.FederatedClaims.ConnectorID, c.Issuer}
+	}
+	return []string{c.Issuer}
+}
@@ -16,10 +16,8 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"context"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/tls"
 	"encoding/json"
 	"flag"
@@ -30,6 +28,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -131,18 +130,60 @@ var (
 	grpcPort    int
 	disableGrpc bool
 
-	retries        uint
-	oneTime        bool
-	runWriteProber bool
+	retries               uint
+	oneTime               bool
+	runWriteProber        bool
+	runKeyOnlyWriteProber bool
+	runNegativeProbes     bool
 
 	rekorV2URL string
 
+	clockSkew         time.Duration
+	writePolicy       string
+	fulcioRequestMode string
+
+	keyAlgorithmList      []string
+	keyAlgorithmSelection string
+
+	rekorV1EntryKindList []string
+	rekorV2EntryKindList []string
+
+	rekorV2IntegrationTimeout time.Duration
+
+	identityIssuer    string
+	identitySANRegexp string
+
+	fulcioCertLifetime time.Duration
+
+	attestationPredicatePath string
+	bundleCorpusDir          string
+	expectedOIDCIssuers      string
+	checkOIDCIssuers         bool
+	oidcIssuerCheckInterval  time.Duration
+	ctLogCheckInterval       time.Duration
+
+	oidcIssuer           string
+	oidcClientID         string
+	oidcClientSecretFile string
+	oidcAudience         string
+	idTokenFile          string
+	idTokenCommand       []string
+	localIssuerURL       string
+	localIssuerSubject   string
+
+	embeddedIssuer *localIssuer
+
+	fulcioIdentities []tokenSource
+
 	versionInfo version.Info
 )
 
 type attemptCtxKey string
 
-func init() {
+// parseFlags parses and validates the flags and configures the logger and
+// HTTP client accordingly. It runs at the start of main rather than in init,
+// so that tests of the package are not given the flags of the test binary.
+func parseFlags() {
 	flag.StringVar(&scPath, "signing-config", "", "Path to the signing config")
 	flag.StringVar(&trPath, "trusted-root", "", "Path to the trusted root")
 
@@ -157,8 +198,39 @@ func init() {
 	flag.UintVar(&retries, "retry", 4, "Maximum number of retries before marking HTTP request as failed")
 	flag.BoolVar(&oneTime, "one-time", false, "Whether to run only one time and exit")
 	flag.BoolVar(&runWriteProber, "write-prober", false, "Whether to run the probers for the write endpoints")
+	flag.BoolVar(&runKeyOnlyWriteProber, "key-only-write-prober", false, "Whether to run the Rekor write probers with the public key of the probe key instead of a Fulcio certificate, independently of write-prober, Fulcio and OIDC")
+	flag.BoolVar(&runNegativeProbes, "negative-probes", false, "Whether the write probers also send invalid requests that the services must reject, which the services log and may alert on as client errors")
 
 	flag.StringVar(&rekorV2URL, "rekor-v2-url", "", "Set to the Rekor v2 URL to run probers against (will take precedence over any instances listed in the signing config)")
+	flag.StringVar(&writePolicy, "write-policy", writePolicyAll, "Whether all services (all) or at least one service (any) of each kind must pass the write probers")
+	flag.DurationVar(&rekorV2IntegrationTimeout, "rekor-v2-integration-timeout", time.Minute, "Maximum time for an entry written to Rekor v2 to become visible in the published checkpoint and entry bundles")
+	flag.StringVar(&identityIssuer, "identity-issuer", "", "Expected OIDC issuer of the certificate when verifying signed bundles (defaults to the issuer of the identity token)")
+	flag.StringVar(&identitySANRegexp, "identity-san-regexp", ".+", "Regular expression the certificate SAN must match when verifying signed bundles")
+	flag.StringVar(&fulcioRequestMode, "fulcio-request-mode", fulcioRequestModeRotate, "How the Fulcio write prober requests certificates: with a public key (public-key), a PKCS#10 CSR (csr), or alternating between them (rotate)")
+	var keyAlgorithmsFlag string
+	flag.StringVar(&keyAlgorithmsFlag, "key-algorithms", "ecdsa-sha2-256-nistp256", fmt.Sprintf("Comma-separated list of the key algorithms the write probers sign with, from %s", strings.Join(keyAlgorithms, ", ")))
+	flag.StringVar(&keyAlgorithmSelection, "key-algorithm-selection", keyAlgorithmSelectionRotate, "Whether each write probe cycle signs with the next of the key-algorithms in turn (rotate) or with each of them (all)")
+	var rekorV1EntryKindsFlag string
+	flag.StringVar(&rekorV1EntryKindsFlag, "rekor-v1-entry-kinds", rekorV1KindHashedRekord, "Comma-separated list of the kinds of entries the Rekor v1 write prober adds: hashedrekord, rekord, intoto and dsse")
+	var rekorV2EntryKindsFlag string
+	flag.StringVar(&rekorV2EntryKindsFlag, "rekor-v2-entry-kinds", rekorV2KindHashedRekord+","+rekorV2KindDSSE, "Comma-separated list of the kinds of entries the Rekor v2 write prober adds: hashedrekord and dsse, both of which every Rekor v2 log accepts")
+	flag.DurationVar(&fulcioCertLifetime, "fulcio-cert-lifetime", 10*time.Minute, "Expected validity period of certificates issued by Fulcio")
+	flag.StringVar(&attestationPredicatePath, "attestation-predicate", "", "Path to a SLSA provenance predicate to attest in the container image prober (defaults to a built-in predicate)")
+	flag.StringVar(&expectedOIDCIssuers, "expected-oidc-issuers", "", "Comma-separated list of the OIDC issuer URLs (including wildcard URLs) expected in the Fulcio configuration; if empty, the issuers are not compared")
+	flag.BoolVar(&checkOIDCIssuers, "check-oidc-issuers", false, "Whether to check that the discovery documents and keys of the OIDC issuers in the Fulcio configuration can be fetched, reporting them in fulcio_oidc_issuer_reachable")
+	flag.DurationVar(&oidcIssuerCheckInterval, "oidc-issuer-check-interval", time.Hour, "Minimum time between checks of the OIDC issuers with check-oidc-issuers")
+	flag.DurationVar(&ctLogCheckInterval, "ct-log-check-interval", time.Hour, "Minimum time between checks of the CT log keys and accepted roots against the trusted root, which are only checked if Fulcio is probed")
+	flag.StringVar(&oidcIssuer, "oidc-issuer", defaultOIDCIssuer, "OIDC issuer to request identity tokens from with the client credentials grant")
+	flag.StringVar(&oidcClientID, "oidc-client-id", defaultOIDCClientID, "OIDC client ID to request identity tokens with")
+	flag.StringVar(&oidcClientSecretFile, "oidc-client-secret-file", "", "Path to the OIDC client secret; if set, identity tokens are requested from oidc-issuer with the client credentials grant")
+	flag.StringVar(&oidcAudience, "oidc-audience", "sigstore", "Audience of the identity tokens requested for the write probers")
+	flag.StringVar(&idTokenFile, "id-token-file", "", "Path to an identity token for the write probers, re-read every cycle so it can be refreshed externally")
+	var idTokenCommandJSON string
+	flag.StringVar(&idTokenCommandJSON, "id-token-command", "", `Command run (without a shell) to print an identity token for the write probers, again whenever the last token is about to expire (JSON array of the program and its arguments, e.g. ["gcloud", "auth", "print-identity-token"])`)
+	flag.StringVar(&localIssuerURL, "local-oidc-issuer", "", "URL of an OIDC issuer embedded in the prober, served on addr under the URL path, that mints identity tokens for the write probers; Fulcio must be configured to trust it")
+	flag.StringVar(&localIssuerSubject, "local-oidc-subject", "sigstore-prober@example.com", "Subject and email of the identity tokens minted by local-oidc-issuer")
+	flag.StringVar(&bundleCorpusDir, "bundle-corpus", "", "Directory of stored bundles to re-verify against the trusted root every cycle; the write prober adds a bundle each time the signing keys change")
+	flag.DurationVar(&clockSkew, "clock-skew", time.Minute, "Maximum allowed difference between local time and the times issued by the services: the integrated times of written log entries, the timestamps of SCTs and the validity start of certificates")
 
 	var rekorV1RequestsJSON string
 	flag.StringVar(&rekorV1RequestsJSON, "rekor-requests", "[]", "Additional rekor requests (JSON array)")
@@ -166,9 +238,50 @@ func init() {
 	var fulcioRequestsJSON string
 	flag.StringVar(&fulcioRequestsJSON, "fulcio-requests", "[]", "Additional fulcio requests (JSON array)")
 
+	var fulcioIdentitiesJSON string
+	flag.StringVar(&fulcioIdentitiesJSON, "fulcio-identities", "[]", `Additional token sources to probe Fulcio issuance with (JSON array of objects with "name", "type" (email, github-workflow, kubernetes, spiffe or uri), and "local", "tokenFile", "tokenCommand", "issuer", "clientID", "clientSecretFile" and "audience", as the corresponding flags, which are the defaults); only the Fulcio v2 HTTP write prober requests certificates with them, the gRPC, v1, bundle and container image write probers use the flag token source`)
+
 	flag.Parse()
 
 	ConfigureLogger(logStyle)
+	if writePolicy != writePolicyAll && writePolicy != writePolicyAny {
+		log.Fatalf("Invalid write-policy %q, must be %q or %q", writePolicy, writePolicyAll, writePolicyAny)
+	}
+	if !slices.Contains([]string{fulcioRequestModePublicKey, fulcioRequestModeCSR, fulcioRequestModeRotate}, fulcioRequestMode) {
+		log.Fatalf("Invalid fulcio-request-mode %q, must be %q, %q or %q", fulcioRequestMode, fulcioRequestModePublicKey, fulcioRequestModeCSR, fulcioRequestModeRotate)
+	}
+	if keyAlgorithmSelection != keyAlgorithmSelectionRotate && keyAlgorithmSelection != keyAlgorithmSelectionAll {
+		log.Fatalf("Invalid key-algorithm-selection %q, must be %q or %q", keyAlgorithmSelection, keyAlgorithmSelectionRotate, keyAlgorithmSelectionAll)
+	}
+	keyAlgorithmList = strings.Split(keyAlgorithmsFlag, ",")
+	for _, algorithm := range keyAlgorithmList {
+		if !slices.Contains(keyAlgorithms, algorithm) {
+			log.Fatalf("Invalid key-algorithms %q, must be one of %v", algorithm, keyAlgorithms)
+		}
+	}
+	rekorV1EntryKindList = strings.Split(rekorV1EntryKindsFlag, ",")
+	for _, kind := range rekorV1EntryKindList {
+		if _, ok := rekorV1KindVersions[kind]; !ok {
+			log.Fatalf("Invalid rekor-v1-entry-kinds %q, must be one of %q, %q, %q or %q", kind, rekorV1KindHashedRekord, rekorV1KindRekord, rekorV1KindIntoto, rekorV1KindDSSE)
+		}
+	}
+	rekorV2EntryKindList = strings.Split(rekorV2EntryKindsFlag, ",")
+	for _, kind := range rekorV2EntryKindList {
+		if _, ok := rekorV2KindVersions[kind]; !ok {
+			log.Fatalf("Invalid rekor-v2-entry-kinds %q, must be one of %q or %q", kind, rekorV2KindHashedRekord, rekorV2KindDSSE)
+		}
+	}
+	if tokenSources := len(slices.DeleteFunc([]string{localIssuerURL, idTokenFile, idTokenCommandJSON, oidcClientSecretFile}, func(s string) bool { return s == "" })); tokenSources > 1 {
+		log.Fatalf("Invalid token source, only one of local-oidc-issuer, id-token-file, id-token-command and oidc-client-secret-file may be set")
+	}
+	if idTokenCommandJSON != "" {
+		if err := json.Unmarshal([]byte(idTokenCommandJSON), &idTokenCommand); err != nil {
+			log.Fatal("Failed to parse id-token-command: ", err)
+		}
+		if err := checkTokenCommand(idTokenCommand); err != nil {
+			log.Fatalf("Invalid id-token-command %q: %v", idTokenCommand, err)
+		}
+	}
 	retryableClient = retryablehttp.NewClient()
 	retryableClient.Logger = Logger
 	retryableClient.RetryMax = int(retries)
@@ -187,6 +300,28 @@ func init() {
 		log.Fatal("Failed to parse rekor-requests: ", err)
 	}
 
+	if err := json.Unmarshal([]byte(fulcioIdentitiesJSON), &fulcioIdentities); err != nil {
+		log.Fatal("Failed to parse fulcio-identities: ", err)
+	}
+	names := []string{defaultTokenSourceName}
+	for i, identity := range fulcioIdentities {
+		if identity.Name == "" || slices.Contains(names, identity.Name) {
+			log.Fatalf("Invalid fulcio-identities, name %q is empty or not unique", identity.Name)
+		}
+		if identity.Type != "" && !slices.Contains(identityTypes, identity.Type) {
+			log.Fatalf("Invalid fulcio-identities, type %q of %s must be one of %v", identity.Type, identity.Name, identityTypes)
+		}
+		if identity.TokenCommand != nil {
+			if err := checkTokenCommand(identity.TokenCommand); err != nil {
+				log.Fatalf("Invalid fulcio-identities, tokenCommand %q of %s: %v", identity.TokenCommand, identity.Name, err)
+			}
+		}
+		names = append(names, identity.Name)
+		fulcioIdentities[i].Issuer = cmp.Or(identity.Issuer, oidcIssuer)
+		fulcioIdentities[i].ClientID = cmp.Or(identity.ClientID, oidcClientID)
+		fulcioIdentities[i].Audience = cmp.Or(identity.Audience, oidcAudience)
+	}
+
 	var fulcioFlagRequests []ReadProberCheck
 	if err := json.Unmarshal([]byte(fulcioRequestsJSON), &fulcioFlagRequests); err != nil {
 		log.Fatal("Failed to parse fulcio-requests: ", err)
@@ -197,19 +332,15 @@ func init() {
 }
 
 func main() {
+	parseFlags()
 	ctx := context.Background()
 	versionInfo = version.GetVersionInfo()
 	Logger.Infof("running prober Version: %s GitCommit: %s BuildDate: %s", versionInfo.GitVersion, versionInfo.GitCommit, versionInfo.BuildDate)
 
 	reg := prometheus.NewRegistry()
-	reg.MustRegister(endpointLatenciesSummary, endpointLatenciesHistogram, verificationCounter)
+	reg.MustRegister(endpointLatenciesSummary, endpointLatenciesHistogram, verificationCounter, verificationFailureCounter, writeProbeCounter, rekorV2IntegrationLatency, rekorV2IntegrationCounter, bundleSignVerifyCounter, imageSignVerifyCounter, historicalBundleVerified, sctCounter, oidcIssuerReachable, fulcioIdentityWriteCounter, writeRejectionCounter, rekorV1HashSearchCounter)
 	reg.MustRegister(NewVersionCollector("sigstore_prober"))
 
-	// Ensure that we report zeroed failures on verifications.  This allows us to
-	// detect on alert on the "never seen" --> "seen once" transition.
-	verificationCounter.With(prometheus.Labels{verifiedLabel: "false"}).Add(0)
-	verificationCounter.With(prometheus.Labels{verifiedLabel: "true"}).Add(0)
-
 	var err error
 
 	var signingConfig *root.SigningConfig
@@ -266,9 +397,22 @@ func main() {
 		}
 	}
 
-	fulcioService, err := root.SelectService(signingConfig.FulcioCertificateAuthorityURLs(), sign.FulcioAPIVersions, time.Now())
-	if err != nil {
-		log.Fatal("Failed to select Fulcio service: ", err)
+	// Ensure that we report zeroed failures on verifications.  This allows us to
+	// detect on alert on the "never seen" --> "seen once" transition.
+	for _, s := range slices.Concat(rekorV1Services, rekorV2Services) {
+		verificationCounter.With(prometheus.Labels{hostLabel: s.URL, verifiedLabel: "false"}).Add(0)
+		verificationCounter.With(prometheus.Labels{hostLabel: s.URL, verifiedLabel: "true"}).Add(0)
+	}
+
+	// Deployments without Fulcio can still be probed, with the key-only
+	// write prober, so Fulcio is only required if the signing config lists
+	// any or the write probers need it.
+	var fulcioService root.Service
+	if len(signingConfig.FulcioCertificateAuthorityURLs()) > 0 || runWriteProber {
+		fulcioService, err = root.SelectService(signingConfig.FulcioCertificateAuthorityURLs(), sign.FulcioAPIVersions, time.Now())
+		if err != nil {
+			log.Fatal("Failed to select Fulcio service: ", err)
+		}
 	}
 
 	fulcioGrpcURL := fulcioService.URL
@@ -291,14 +435,22 @@ func main() {
 	}
 
 	var fulcioClient fulciopb.CAClient
-	if !disableGrpc {
+	if !disableGrpc && fulcioService.URL != "" {
 		var err error
 		fulcioClient, err = NewFulcioGrpcClient(fulcioGrpcURL)
 		if err != nil {
 			Logger.Fatalf("error creating fulcio grpc client %v", err)
 		}
 	}
-	go runProbers(ctx, frequency, oneTime, fulcioClient, rekorV1Services, rekorV2Services, fulcioService, fulcioGrpcURL, tsaServices, trustedRoot)
+	if localIssuerURL != "" {
+		embeddedIssuer, err = newLocalIssuer(localIssuerURL)
+		if err != nil {
+			log.Fatal("Failed to create local OIDC issuer: ", err)
+		}
+		if err := embeddedIssuer.register(http.DefaultServeMux); err != nil {
+			log.Fatal("Failed to serve local OIDC issuer: ", err)
+		}
+	}
 	// Expose the registered metrics via HTTP.
 	http.Handle("/metrics", promhttp.HandlerFor(
 		reg,
@@ -307,9 +459,16 @@ func main() {
 			EnableOpenMetrics: true,
 		},
 	))
+	// Listen before the probers start, so that the embedded OIDC issuer can
+	// be reached as soon as Fulcio verifies the first token.
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("Failed to listen: ", err)
+	}
+	go runProbers(ctx, frequency, oneTime, fulcioClient, rekorV1Services, rekorV2Services, fulcioService, fulcioGrpcURL, tsaServices, trustedRoot)
 	Logger.Infof("Starting Prometheus Server on port %s", addr)
 	/* #nosec G114 */
-	Logger.Fatal(http.ListenAndServe(addr, nil))
+	Logger.Fatal(http.Serve(listener, nil))
 }
 
 func NewFulcioGrpcClient(fulcioGrpcURL string) (fulciopb.CAClient, error) {
@@ -356,10 +515,22 @@ func runProbers(ctx context.Context, freq int, runOnce bool, fulcioGrpcClient fu
 
 			rekorEndpointsUnderTest = append(rekorEndpointsUnderTest, ShardlessRekorEndpoints...)
 
+			var activeKey []byte
 			for _, r := range rekorEndpointsUnderTest {
-				if _, err := observeRequest(s.URL, r); err != nil {
+				body, err := observeRequest(s.URL, r)
+				if err != nil {
 					hasErr = true
 					Logger.Errorf("error running request %s: %v", r.Endpoint, err)
+					continue
+				}
+				if r.Endpoint == rekorPublicKeyEndpoint && len(r.Queries) == 0 {
+					activeKey = body
+				}
+			}
+			if activeKey != nil {
+				if err := rekorV1VerifyPublicKeys(s.URL, activeKey, logInfo, trustedRoot); err != nil {
+					hasErr = true
+					Logger.Errorf("error verifying rekor public keys for %s: %v", s.URL, err)
 				}
 			}
 		}
@@ -371,12 +542,30 @@ func runProbers(ctx context.Context, freq int, runOnce bool, fulcioGrpcClient fu
 					Logger.Errorf("error running request %s: %v", r.Endpoint, err)
 				}
 			}
+			if err := rekorV2ReadTiles(ctx, s, trustedRoot); err != nil {
+				hasErr = true
+				Logger.Errorf("error reading rekor v2 tiles from %s: %v", s.URL, err)
+			}
 		}
 
-		for _, r := range FulcioEndpoints {
-			if _, err := observeRequest(fulcioService.URL, r); err != nil {
+		// the CT logs are only used by Fulcio
+		if fulcioService.URL != "" {
+			if err := verifyCTLogKeys(fulcioService, trustedRoot); err != nil {
 				hasErr = true
-				Logger.Errorf("error running request %s: %v", r.Endpoint, err)
+				Logger.Errorf("error verifying CT log keys: %v", err)
+			}
+		}
+
+		fulcioResponses := map[string][]byte{}
+		if fulcioService.URL != "" {
+			for _, r := range FulcioEndpoints {
+				body, err := observeRequest(fulcioService.URL, r)
+				if err != nil {
+					hasErr = true
+					Logger.Errorf("error running request %s: %v", r.Endpoint, err)
+					continue
+				}
+				fulcioResponses[r.Endpoint] = body
 			}
 		}
 
@@ -389,46 +578,138 @@ func runProbers(ctx context.Context, freq int, runOnce bool, fulcioGrpcClient fu
 			}
 		}
 
+		if bundleCorpusDir != "" {
+			if err := historicalBundlesVerify(trustedRoot); err != nil {
+				hasErr = true
+				Logger.Errorf("error verifying historical bundles: %v", err)
+			}
+		}
+
 		// Performing requests for GetTrustBundle against Fulcio gRPC API
+		var grpcTrustBundle *fulciopb.TrustBundle
 		if fulcioGrpcClient != nil {
-			if err := observeGrpcGetTrustBundleRequest(ctx, fulcioGrpcClient, fulcioGrpcURL); err != nil {
+			var err error
+			if grpcTrustBundle, err = observeGrpcGetTrustBundleRequest(ctx, fulcioGrpcClient, fulcioGrpcURL); err != nil {
 				hasErr = true
 				Logger.Errorf("error running request %s: %v", "GetTrustBundle", err)
 			}
+			if err := observeGrpcGetConfigurationRequest(ctx, fulcioGrpcClient, fulcioGrpcURL); err != nil {
+				hasErr = true
+				Logger.Errorf("error running request %s: %v", "GetConfiguration", err)
+			}
 		}
 
-		if runWriteProber {
-			priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-			if err != nil {
-				Logger.Fatalf("failed to generate key: %v", err)
+		if configuration, ok := fulcioResponses[fulcioConfigurationEndpoint]; ok {
+			if err := verifyFulcioIssuers(fulcioService, configuration); err != nil {
+				hasErr = true
+				Logger.Errorf("error verifying fulcio OIDC issuers: %v", err)
 			}
+		}
 
-			cert, err := fulcioWriteEndpoint(ctx, priv, fulcioService, trustedRoot)
-			if err != nil {
+		if fulcioService.URL != "" {
+			if err := verifyFulcioTrustBundles(fulcioService, fulcioResponses, grpcTrustBundle, trustedRoot); err != nil {
 				hasErr = true
-				Logger.Errorf("error running fulcio v2 write prober: %v", err)
+				Logger.Errorf("error verifying fulcio trust bundles: %v", err)
 			}
-			_, err = fulcioWriteLegacyEndpoint(ctx, priv, fulcioService)
-			if err != nil {
-				hasErr = true
-				Logger.Errorf("error running fulcio v1 write prober: %v", err)
+		}
+
+		// both write probers sign with the same key algorithms in a cycle,
+		// and wait for their Rekor v2 entries together once all are written
+		var algorithms []string
+		integrations := &rekorV2Integrations{}
+		if runWriteProber || runKeyOnlyWriteProber {
+			algorithms = nextKeyAlgorithms()
+		}
+
+		if runWriteProber {
+			mode := nextFulcioRequestMode()
+			for _, algorithm := range algorithms {
+				key, err := newSigningKey(algorithm)
+				if err != nil {
+					Logger.Fatalf("failed to generate %s key: %v", algorithm, err)
+				}
+
+				cert, err := fulcioWriteEndpoint(ctx, key, mode, flagTokenSource(), fulcioService, trustedRoot)
+				if err != nil {
+					hasErr = true
+					Logger.Errorf("error running fulcio v2 write prober with %s: %v", algorithm, err)
+				}
+				for _, identity := range fulcioIdentities {
+					if _, err := fulcioWriteEndpoint(ctx, key, mode, identity, fulcioService, trustedRoot); err != nil {
+						hasErr = true
+						Logger.Errorf("error running fulcio v2 write prober for %s with %s: %v", identity.Name, algorithm, err)
+					}
+				}
+				if fulcioGrpcClient != nil {
+					if err := fulcioGrpcWriteEndpoint(ctx, key, mode, flagTokenSource(), fulcioGrpcClient, fulcioGrpcURL, fulcioService, trustedRoot); err != nil {
+						hasErr = true
+						Logger.Errorf("error running fulcio gRPC write prober with %s: %v", algorithm, err)
+					}
+				}
+				_, err = fulcioWriteLegacyEndpoint(ctx, key, fulcioService, trustedRoot)
+				if err != nil {
+					hasErr = true
+					Logger.Errorf("error running fulcio v1 write prober with %s: %v", algorithm, err)
+				}
+				if len(rekorV1Services) > 0 {
+					if err := rekorV1WriteEndpoint(ctx, rekorV1WriteProbe, cert, key, rekorV1Services, trustedRoot); err != nil {
+						hasErr = true
+						Logger.Errorf("error running rekor write prober with %s: %v", algorithm, err)
+					}
+				}
+				if err := tsaWriteEndpoint(ctx, key, tsaServices, trustedRoot); err != nil {
+					hasErr = true
+					Logger.Errorf("error running tsa write prober with %s: %v", algorithm, err)
+				}
+				if len(rekorV2Services) > 0 {
+					if err := rekorV2WriteEndpoint(ctx, rekorV2WriteProbe, cert, key, rekorV2Services, integrations, trustedRoot); err != nil {
+						hasErr = true
+						Logger.Errorf("error running rekor v2 write prober with %s: %v", algorithm, err)
+					}
+				}
 			}
-			if err := rekorV1WriteEndpoint(ctx, cert, priv, rekorV1Services, trustedRoot); err != nil {
+			if err := bundleSignVerifyEndpoint(ctx, fulcioService, rekorV1Services, rekorV2Services, tsaServices, trustedRoot); err != nil {
 				hasErr = true
-				Logger.Errorf("error running rekor write prober: %v", err)
+				Logger.Errorf("error running bundle sign and verify prober: %v", err)
 			}
-			if err := tsaWriteEndpoint(ctx, priv, tsaServices, trustedRoot); err != nil {
+			if err := imageSignAttestEndpoint(ctx, fulcioService, rekorV1Services, rekorV2Services, tsaServices, trustedRoot); err != nil {
 				hasErr = true
-				Logger.Errorf("error running tsa write prober: %v", err)
+				Logger.Errorf("error running container image sign and attest prober: %v", err)
 			}
-			if len(rekorV2Services) > 0 {
-				if err := rekorV2WriteEndpoint(ctx, cert, priv, rekorV2Services); err != nil {
+			if runNegativeProbes {
+				if err := negativeWriteEndpoints(ctx, fulcioGrpcClient, fulcioGrpcURL, fulcioService, rekorV1Services, rekorV2Services, tsaServices); err != nil {
 					hasErr = true
-					Logger.Errorf("error running rekor v2 write prober: %v", err)
+					Logger.Errorf("error running negative write probers: %v", err)
 				}
 			}
 		}
 
+		if runKeyOnlyWriteProber {
+			for _, algorithm := range algorithms {
+				key, err := newSigningKey(algorithm)
+				if err != nil {
+					Logger.Fatalf("failed to generate %s key: %v", algorithm, err)
+				}
+				if len(rekorV1Services) > 0 {
+					if err := rekorV1WriteEndpoint(ctx, rekorV1KeyOnlyWriteProbe, nil, key, rekorV1Services, trustedRoot); err != nil {
+						hasErr = true
+						Logger.Errorf("error running key-only rekor write prober with %s: %v", algorithm, err)
+					}
+				}
+				if len(rekorV2Services) > 0 {
+					if err := rekorV2WriteEndpoint(ctx, rekorV2KeyOnlyWriteProbe, nil, key, rekorV2Services, integrations, trustedRoot); err != nil {
+						hasErr = true
+						Logger.Errorf("error running key-only rekor v2 write prober with %s: %v", algorithm, err)
+					}
+				}
+			}
+		}
+
+		if err := integrations.wait(ctx, trustedRoot); err != nil {
+			hasErr = true
+			Logger.Errorf("error waiting for rekor v2 entries to be integrated: %v", err)
+		}
+
 		if runOnce {
 			if hasErr {
 				Logger.Fatal("Failed")
@@ -471,19 +752,39 @@ func observeRequest(host string, r ReadProberCheck) ([]byte, error) {
 	if _, err := io.Copy(&respBuffer, resp.Body); err != nil {
 		return nil, fmt.Errorf("error reading response: %w", err)
 	}
+	if resp.StatusCode == http.StatusNotFound {
+		// Wrap os.ErrNotExist so callers such as the tile fetchers can tell
+		// a missing resource apart from other failures.
+		return respBuffer.Bytes(), fmt.Errorf("error response: status: %s, body: %s: %w", resp.Status, respBuffer.String(), os.ErrNotExist)
+	}
 	if resp.StatusCode >= 300 {
 		return respBuffer.Bytes(), fmt.Errorf("error response: status: %s, body: %s", resp.Status, respBuffer.String())
 	}
 	return respBuffer.Bytes(), nil
 }
 
-func observeGrpcGetTrustBundleRequest(ctx context.Context, fulcioGrpcClient fulciopb.CAClient, fulcioGrpcURL string) error {
+func observeGrpcGetTrustBundleRequest(ctx context.Context, fulcioGrpcClient fulciopb.CAClient, fulcioGrpcURL string) (*fulciopb.TrustBundle, error) {
 	s := time.Now()
-	_, err := fulcioGrpcClient.GetTrustBundle(ctx, &fulciopb.GetTrustBundleRequest{})
+	resp, err := fulcioGrpcClient.GetTrustBundle(ctx, &fulciopb.GetTrustBundleRequest{})
 
 	latency := time.Since(s).Milliseconds()
 	exportGrpcDataToPrometheus(status.Code(err), "grpc://"+fulcioGrpcURL, "GetTrustBundle", "GET", latency)
-	return err
+	return resp, err
+}
+
+func observeGrpcGetConfigurationRequest(ctx context.Context, fulcioGrpcClient fulciopb.CAClient, fulcioGrpcURL string) error {
+	s := time.Now()
+	resp, err := fulcioGrpcClient.GetConfiguration(ctx, &fulciopb.GetConfigurationRequest{})
+
+	latency := time.Since(s).Milliseconds()
+	exportGrpcDataToPrometheus(status.Code(err), "grpc://"+fulcioGrpcURL, "GetConfiguration", "GET", latency)
+	if err != nil {
+		return err
+	}
+	if len(resp.GetIssuers()) == 0 {
+		return fmt.Errorf("configuration lists no OIDC issuers")
+	}
+	return nil
 }
 
 func httpRequest(host string, r ReadProberCheck) (*retryablehttp.Request, error) {
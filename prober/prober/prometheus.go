@@ -30,8 +30,26 @@ var (
 	statusCodeLabel = "status_code"
 	methodLabel     = "method"
 	verifiedLabel   = "verified"
+	reasonLabel     = "reason"
+	probeLabel      = "probe"
+	successLabel    = "success"
+	bundleLabel     = "bundle"
+	fulcioCALabel   = "fulcio_ca"
+	rekorLogLabel   = "rekor_log"
+	ctLogLabel      = "ct_log"
+	tsaLabel        = "tsa"
+
+	responseTypeLabel = "response_type"
+	issuerLabel       = "issuer"
+	identityLabel     = "identity"
+	algorithmLabel    = "algorithm"
+	outcomeLabel      = "outcome"
 )
 
+// issuerUnknown is the issuer label of Fulcio identity writes that failed
+// before the issuer of the token was known.
+const issuerUnknown = "unknown"
+
 var (
 	// Track latency for each endpoint
 	endpointLatenciesSummary = prometheus.NewSummaryVec(
@@ -55,7 +73,102 @@ var (
 			Name: "verification",
 			Help: "Rekor verification correctness counter",
 		},
-		[]string{verifiedLabel},
+		[]string{hostLabel, verifiedLabel},
+	)
+
+	rekorV2IntegrationLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rekor_v2_integration_latency",
+		Help:    "Time from submitting an entry to Rekor v2 until it is visible in the published checkpoint and entry bundles (milliseconds)",
+		Buckets: []float64{1000.0, 2000.0, 5000.0, 10000.0, 20000.0, 30000.0, 60000.0},
+	},
+		[]string{hostLabel})
+
+	rekorV2IntegrationCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rekor_v2_integration",
+			Help: "Outcomes of waiting for entries written to Rekor v2 to become visible in the published checkpoint and entry bundles: visible, timeout or error",
+		},
+		[]string{hostLabel, outcomeLabel},
+	)
+
+	bundleSignVerifyCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bundle_sign_verify",
+			Help: "Outcomes of signing and verifying a complete Sigstore bundle, by Rekor service",
+		},
+		[]string{hostLabel, successLabel},
+	)
+
+	imageSignVerifyCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "image_sign_verify",
+			Help: "Outcomes of signing, attesting and verifying a container image in an in-process registry, by Rekor service",
+		},
+		[]string{hostLabel, successLabel},
+	)
+
+	sctCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fulcio_sct",
+			Help: "SCTs for certificates issued by Fulcio, by the CT log that issued them, whether they were embedded or detached, and whether they verified",
+		},
+		[]string{hostLabel, ctLogLabel, responseTypeLabel, verifiedLabel},
+	)
+
+	historicalBundleVerified = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "historical_bundle_verified",
+			Help: "Whether a stored bundle still verifies against the current trusted root (1) or not (0), by the Fulcio CA, Rekor log, CT log and TSA it depends on",
+		},
+		[]string{bundleLabel, fulcioCALabel, rekorLogLabel, ctLogLabel, tsaLabel},
+	)
+
+	oidcIssuerReachable = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "fulcio_oidc_issuer_reachable",
+			Help: "Whether the discovery document and keys of an OIDC issuer configured in Fulcio could be fetched (1) or not (0)",
+		},
+		[]string{hostLabel, issuerLabel},
+	)
+
+	fulcioIdentityWriteCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fulcio_identity_write",
+			Help: "Fulcio write prober outcomes by token source and the issuer of its tokens",
+		},
+		[]string{hostLabel, identityLabel, issuerLabel, successLabel},
+	)
+
+	writeProbeCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "write_probe",
+			Help: "Write prober outcomes by probe, service and key algorithm",
+		},
+		[]string{probeLabel, hostLabel, algorithmLabel, successLabel},
+	)
+
+	writeRejectionCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "write_rejection",
+			Help: "Negative write prober outcomes by probe and service: whether the invalid request was rejected as expected (rejected), accepted (accepted), or the probe failed (error)",
+		},
+		[]string{probeLabel, hostLabel, outcomeLabel},
+	)
+
+	rekorV1HashSearchCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rekor_v1_hash_search",
+			Help: "Whether entries written to Rekor v1 were found by searching the index by artifact hash (searched), or not searched for as the index does not accept the hash algorithm of the entry (skipped)",
+		},
+		[]string{hostLabel, outcomeLabel},
+	)
+
+	verificationFailureCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "verification_failure",
+			Help: "Verification failures by host and reason",
+		},
+		[]string{hostLabel, reasonLabel},
 	)
 )
 
@@ -0,0 +1,223 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/swag/conv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/rekor/pkg/types"
+)
+
+const (
+	// Rekor adds new entries to the search index asynchronously, so
+	// index lookups are retried for a short while before failing.
+	rekorIndexPollAttempts = 5
+	rekorIndexPollInterval = time.Second
+
+	// entry UUIDs are 64 hex characters, optionally prefixed by a 16
+	// character tree ID to form the entry ID returned by sharded logs.
+	rekorUUIDLen = 64
+
+	// outcomes of searching for new entries by artifact hash recorded by
+	// rekorV1HashSearchCounter
+	hashSearchOutcomeSearched = "searched"
+	hashSearchOutcomeSkipped  = "skipped"
+)
+
+// rekorV1ReadAfterWrite fetches a freshly written entry through every lookup
+// path offered by Rekor v1 and checks that each one returns the same entry:
+// by UUID, by log index, via /api/v1/log/entries/retrieve, and via
+// /api/v1/index/retrieve by artifact hash, if the index accepts its hash
+// algorithm, and by public key.
+func rekorV1ReadAfterWrite(host, entryID string, want models.LogEntryAnon, entry types.EntryImpl) error {
+	byUUID := ReadProberCheck{
+		Endpoint:    rekorEndpoint + "/" + entryID,
+		Method:      GET,
+		SLOEndpoint: rekorEndpoint + "/{entryUUID}",
+	}
+	if err := rekorV1ReadEntry(host, byUUID, entryID, want); err != nil {
+		return fmt.Errorf("get by uuid: %w", err)
+	}
+
+	byIndex := ReadProberCheck{
+		Endpoint: rekorEndpoint,
+		Method:   GET,
+		Queries:  map[string]string{"logIndex": strconv.FormatInt(conv.Value(want.LogIndex), 10)},
+	}
+	if err := rekorV1ReadEntry(host, byIndex, entryID, want); err != nil {
+		return fmt.Errorf("get by log index: %w", err)
+	}
+
+	if err := rekorV1RetrieveEntry(host, entryID, want); err != nil {
+		return fmt.Errorf("retrieve by uuid: %w", err)
+	}
+
+	artifactHash, err := entry.ArtifactHash()
+	if err != nil {
+		return err
+	}
+	byHash := models.SearchIndex{
+		Hash: artifactHash,
+	}
+	// the search index only accepts SHA-1, SHA-256 and SHA-512 digests, so
+	// entries hashed otherwise, such as hashedrekord entries signed with
+	// P-384 keys, are only searched for by public key
+	if err := byHash.Validate(strfmt.Default); err != nil {
+		Logger.Infof("not searching %s by hash %s: %v", host, artifactHash, err)
+		rekorV1HashSearchCounter.With(prometheus.Labels{hostLabel: host, outcomeLabel: hashSearchOutcomeSkipped}).Inc()
+	} else {
+		if err := rekorV1SearchIndex(host, byHash, entryID); err != nil {
+			return fmt.Errorf("search index by hash: %w", err)
+		}
+		rekorV1HashSearchCounter.With(prometheus.Labels{hostLabel: host, outcomeLabel: hashSearchOutcomeSearched}).Inc()
+	}
+
+	verifiers, err := entry.Verifiers()
+	if err != nil {
+		return err
+	}
+	verifier, err := verifiers[0].CanonicalValue()
+	if err != nil {
+		return err
+	}
+	byPublicKey := models.SearchIndex{
+		PublicKey: &models.SearchIndexPublicKey{
+			Format:  conv.Pointer(models.SearchIndexPublicKeyFormatX509),
+			Content: strfmt.Base64(verifier),
+		},
+	}
+	if err := rekorV1SearchIndex(host, byPublicKey, entryID); err != nil {
+		return fmt.Errorf("search index by public key: %w", err)
+	}
+	return nil
+}
+
+// rekorV1ReadEntry runs a GET returning a single log entry and compares it
+// to the entry returned on write.
+func rekorV1ReadEntry(host string, r ReadProberCheck, entryID string, want models.LogEntryAnon) error {
+	respBytes, err := observeRequest(host, r)
+	if err != nil {
+		return err
+	}
+	var logEntry models.LogEntry
+	if err := json.Unmarshal(respBytes, &logEntry); err != nil {
+		return fmt.Errorf("decoding log entry: %w", err)
+	}
+	return compareLogEntry(logEntry, entryID, want)
+}
+
+// rekorV1RetrieveEntry searches for the entry by UUID through
+// /api/v1/log/entries/retrieve and compares the result to the entry
+// returned on write.
+func rekorV1RetrieveEntry(host, entryID string, want models.LogEntryAnon) error {
+	body, err := json.Marshal(&models.SearchLogQuery{EntryUUIDs: []string{entryID}})
+	if err != nil {
+		return fmt.Errorf("marshalling search query: %w", err)
+	}
+	respBytes, err := observeRequest(host, ReadProberCheck{
+		Endpoint: rekorEndpoint + "/retrieve",
+		Method:   POST,
+		Body:     body,
+	})
+	if err != nil {
+		return err
+	}
+	var logEntries []models.LogEntry
+	if err := json.Unmarshal(respBytes, &logEntries); err != nil {
+		return fmt.Errorf("decoding log entries: %w", err)
+	}
+	if len(logEntries) != 1 {
+		return fmt.Errorf("unexpected number of entries, got %d, expected 1", len(logEntries))
+	}
+	return compareLogEntry(logEntries[0], entryID, want)
+}
+
+// rekorV1SearchIndex polls /api/v1/index/retrieve until the query returns
+// the written entry.
+func rekorV1SearchIndex(host string, query models.SearchIndex, entryID string) error {
+	body, err := json.Marshal(&query)
+	if err != nil {
+		return fmt.Errorf("marshalling index query: %w", err)
+	}
+	r := ReadProberCheck{
+		Endpoint: "/api/v1/index/retrieve",
+		Method:   POST,
+		Body:     body,
+	}
+	var uuids []string
+	for i := 0; i < rekorIndexPollAttempts; i++ {
+		if i > 0 {
+			time.Sleep(rekorIndexPollInterval)
+		}
+		respBytes, err := observeRequest(host, r)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(respBytes, &uuids); err != nil {
+			return fmt.Errorf("decoding index results: %w", err)
+		}
+		if slices.ContainsFunc(uuids, func(u string) bool { return sameEntryUUID(u, entryID) }) {
+			return nil
+		}
+	}
+	return fmt.Errorf("entry %s not found in %d index results after %d attempts", entryID, len(uuids), rekorIndexPollAttempts)
+}
+
+// compareLogEntry checks that a log entry read from Rekor matches the entry
+// returned when it was written.
+func compareLogEntry(got models.LogEntry, entryID string, want models.LogEntryAnon) error {
+	if len(got) != 1 {
+		return fmt.Errorf("unexpected number of entries, got %d, expected 1", len(got))
+	}
+	for id, e := range got {
+		if !sameEntryUUID(id, entryID) {
+			return fmt.Errorf("entry UUID does not match: got %s, want %s", id, entryID)
+		}
+		if conv.Value(e.LogIndex) != conv.Value(want.LogIndex) {
+			return fmt.Errorf("log index does not match: got %d, want %d", conv.Value(e.LogIndex), conv.Value(want.LogIndex))
+		}
+		if conv.Value(e.LogID) != conv.Value(want.LogID) {
+			return fmt.Errorf("log ID does not match: got %s, want %s", conv.Value(e.LogID), conv.Value(want.LogID))
+		}
+		if conv.Value(e.IntegratedTime) != conv.Value(want.IntegratedTime) {
+			return fmt.Errorf("integrated time does not match: got %d, want %d", conv.Value(e.IntegratedTime), conv.Value(want.IntegratedTime))
+		}
+		if e.Body != want.Body {
+			return fmt.Errorf("entry body does not match")
+		}
+	}
+	return nil
+}
+
+// sameEntryUUID compares two entry IDs, ignoring the tree ID prefix that
+// some endpoints include and others omit.
+func sameEntryUUID(a, b string) bool {
+	trim := func(id string) string {
+		if len(id) > rekorUUIDLen {
+			id = id[len(id)-rekorUUIDLen:]
+		}
+		return strings.ToLower(id)
+	}
+	return trim(a) == trim(b)
+}
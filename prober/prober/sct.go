@@ -0,0 +1,132 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/ctutil"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sigstore/sigstore-go/pkg/root"
+)
+
+// Shapes of the certificate returned by the Fulcio v2 API, reported as the
+// response type of SCTs.
+const (
+	responseTypeEmbeddedSCT = "embedded_sct"
+	responseTypeDetachedSCT = "detached_sct"
+)
+
+// verifyFulcioSCTs verifies the SCTs for a certificate issued by Fulcio
+// against the CT log keys in the trusted root, checks that they were issued
+// recently, and reports the CT log that issued each of them. The SCTs are
+// read from the certificate, or from detachedSCT when Fulcio returned it
+// alongside the certificate. At least one SCT must verify.
+func verifyFulcioSCTs(host string, leaf, issuer *x509.Certificate, detachedSCT []byte, trustedRoot *root.TrustedRoot) error {
+	responseType := responseTypeEmbeddedSCT
+	var scts []*ct.SignedCertificateTimestamp
+	var err error
+	if detachedSCT != nil {
+		responseType = responseTypeDetachedSCT
+		scts, err = parseDetachedSCT(detachedSCT)
+	} else {
+		scts, err = x509util.ParseSCTsFromCertificate(leaf.Raw)
+	}
+	if err != nil {
+		return verificationFailure(host, reasonSCTMissing, fmt.Errorf("parsing %s: %w", responseType, err))
+	}
+	if len(scts) == 0 {
+		return verificationFailure(host, reasonSCTMissing, fmt.Errorf("no SCT in %s response", responseType))
+	}
+	// embedded SCTs are signed over the precertificate, which is rebuilt
+	// from the certificate and its issuer, detached SCTs over the
+	// certificate itself
+	raw := leaf.Raw
+	if responseType == responseTypeEmbeddedSCT {
+		raw = append(append([]byte{}, leaf.Raw...), issuer.Raw...)
+	}
+	chain, err := ctx509.ParseCertificates(raw)
+	if err != nil {
+		return fmt.Errorf("parsing certificate chain: %w", err)
+	}
+
+	ctLogs := trustedRoot.CTLogs()
+	var errs []error
+	verified := 0
+	for _, sct := range scts {
+		logID := hex.EncodeToString(sct.LogID.KeyID[:])
+		err := verifySCT(host, sct, logID, ctLogs, chain, responseType == responseTypeEmbeddedSCT)
+		sctCounter.With(prometheus.Labels{
+			hostLabel:         host,
+			ctLogLabel:        logID,
+			responseTypeLabel: responseType,
+			verifiedLabel:     strconv.FormatBool(err == nil),
+		}).Inc()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("SCT from CT log %s: %w", logID, err))
+			continue
+		}
+		Logger.Debugf("verified %s from CT log %s (%s) for certificate issued by %s", responseType, logID, ctLogs[logID].BaseURL, host)
+		verified++
+	}
+	if verified == 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// parseDetachedSCT decodes a detached SCT, which Fulcio encodes as the JSON
+// response of the CT log's add-chain call.
+func parseDetachedSCT(detachedSCT []byte) ([]*ct.SignedCertificateTimestamp, error) {
+	var addChainResp ct.AddChainResponse
+	if err := json.Unmarshal(detachedSCT, &addChainResp); err != nil {
+		return nil, fmt.Errorf("decoding SCT: %w", err)
+	}
+	sct, err := addChainResp.ToSignedCertificateTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	return []*ct.SignedCertificateTimestamp{sct}, nil
+}
+
+// verifySCT verifies a single SCT over chain with the key of the CT log it
+// names.
+func verifySCT(host string, sct *ct.SignedCertificateTimestamp, logID string, ctLogs map[string]*root.TransparencyLog, chain []*ctx509.Certificate, embedded bool) error {
+	ctLog, ok := ctLogs[logID]
+	if !ok {
+		return verificationFailure(host, reasonSCTUnknownLog, errors.New("CT log is not in the trusted root"))
+	}
+	sctTime := ct.TimestampToTime(sct.Timestamp)
+	if !ctLog.ValidityPeriodStart.IsZero() && sctTime.Before(ctLog.ValidityPeriodStart) ||
+		!ctLog.ValidityPeriodEnd.IsZero() && sctTime.After(ctLog.ValidityPeriodEnd) {
+		return verificationFailure(host, reasonSCTUnknownLog, fmt.Errorf("SCT time %s is outside the validity period of the CT log key", sctTime.UTC().Format(time.RFC3339)))
+	}
+	if err := ctutil.VerifySCT(ctLog.PublicKey, chain, sct, embedded); err != nil {
+		return verificationFailure(host, reasonSCTInvalid, err)
+	}
+	if err := checkTimeSkew("SCT timestamp", sctTime, time.Now()); err != nil {
+		return verificationFailure(host, reasonSCTTimeSkew, err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/options"
+)
+
+const (
+	keyAlgorithmSelectionRotate = "rotate"
+	keyAlgorithmSelectionAll    = "all"
+)
+
+// keyAlgorithms are the algorithms --key-algorithms may list, named as in the
+// flags of the Sigstore services. Ed25519 is only supported prehashed, which
+// is how Rekor verifies hashedrekord entries signed with Ed25519 keys.
+var keyAlgorithms = []string{
+	"ecdsa-sha2-256-nistp256",
+	"ecdsa-sha2-384-nistp384",
+	"ecdsa-sha2-512-nistp521",
+	"ed25519-ph",
+	"rsa-sign-pkcs1-2048-sha256",
+	"rsa-sign-pkcs1-3072-sha256",
+	"rsa-sign-pkcs1-4096-sha256",
+}
+
+// keyAlgorithmCount counts the write probe cycles, to rotate key algorithms.
+var keyAlgorithmCount int
+
+// nextKeyAlgorithms returns the algorithms of --key-algorithms to probe with
+// in the next write probe cycle: all of them, or the next one in turn.
+func nextKeyAlgorithms() []string {
+	if keyAlgorithmSelection == keyAlgorithmSelectionAll {
+		return keyAlgorithmList
+	}
+	algorithm := keyAlgorithmList[keyAlgorithmCount%len(keyAlgorithmList)]
+	keyAlgorithmCount++
+	return []string{algorithm}
+}
+
+// signingKey is an ephemeral key the write probers sign with, and the
+// algorithm it signs artifacts with.
+type signingKey struct {
+	crypto.Signer
+	// name is the --key-algorithms name of the algorithm, used as a metric
+	// label.
+	name    string
+	details signature.AlgorithmDetails
+}
+
+// newSigningKey generates a key for the named algorithm.
+func newSigningKey(name string) (*signingKey, error) {
+	algorithm, err := signature.ParseSignatureAlgorithmFlag(name)
+	if err != nil {
+		return nil, err
+	}
+	details, err := signature.GetAlgorithmDetails(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	var priv crypto.Signer
+	switch details.GetKeyType() {
+	case signature.ECDSA:
+		curve, err := details.GetECDSACurve()
+		if err != nil {
+			return nil, err
+		}
+		priv, err = ecdsa.GenerateKey(*curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+	case signature.RSA:
+		bits, err := details.GetRSAKeySize()
+		if err != nil {
+			return nil, err
+		}
+		priv, err = rsa.GenerateKey(rand.Reader, int(bits))
+		if err != nil {
+			return nil, err
+		}
+	case signature.ED25519:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %s", name)
+	}
+	return &signingKey{Signer: priv, name: name, details: details}, nil
+}
+
+// hash returns the hash function artifacts are digested with before signing.
+func (k *signingKey) hash() crypto.Hash {
+	return k.details.GetHashType()
+}
+
+// digest returns the digest of artifact that signArtifact signs.
+func (k *signingKey) digest(artifact []byte) []byte {
+	h := k.hash().New()
+	h.Write(artifact)
+	return h.Sum(nil)
+}
+
+// signArtifact signs artifact with the algorithm of the key. The Ed25519ph
+// option only applies to Ed25519 keys, and is needed as the algorithm
+// details alone load a pure Ed25519 signer.
+func (k *signingKey) signArtifact(artifact []byte) ([]byte, error) {
+	signer, err := signature.LoadSignerVerifierFromAlgorithmDetails(k.Signer, k.details, options.WithED25519ph())
+	if err != nil {
+		return nil, fmt.Errorf("loading signer verifier: %w", err)
+	}
+	return signer.SignMessage(bytes.NewReader(artifact))
+}
+
+// proveSubject signs the subject of an identity token as proof of
+// possession of the key for Fulcio, which verifies it with the default
+// algorithm for the key type: pure Ed25519 rather than prehashed.
+func (k *signingKey) proveSubject(subject string) ([]byte, error) {
+	signer, err := signature.LoadDefaultSignerVerifier(k.Signer)
+	if err != nil {
+		return nil, fmt.Errorf("loading signer verifier: %w", err)
+	}
+	return signer.SignMessage(bytes.NewReader([]byte(subject)))
+}
+
+// legacySignerVerifier returns a signer for rekord, intoto and dsse entries,
+// whose signatures Rekor v1 verifies with SHA-256, and pure Ed25519, whatever
+// the algorithm of the key.
+func (k *signingKey) legacySignerVerifier() (signature.SignerVerifier, error) {
+	signer, err := signature.LoadSignerVerifier(k.Signer, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("loading signer verifier: %w", err)
+	}
+	return signer, nil
+}
+
+// envelopeSigner returns a signer for DSSE envelopes logged in Rekor v2, which
+// verifies their signatures with the hash of the key details, and pure
+// Ed25519 as for the legacy entry kinds.
+func (k *signingKey) envelopeSigner() (signature.SignerVerifier, error) {
+	signer, err := signature.LoadSignerVerifier(k.Signer, k.hash())
+	if err != nil {
+		return nil, fmt.Errorf("loading signer verifier: %w", err)
+	}
+	return signer, nil
+}
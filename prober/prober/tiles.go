@@ -0,0 +1,401 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	mrand "math/rand/v2"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	rekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/rekor-tiles/v2/pkg/note"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/tessera/api/layout"
+	"github.com/transparency-dev/tessera/client"
+	sumdb_note "golang.org/x/mod/sumdb/note"
+)
+
+const (
+	// number of random entries read from entry bundles on each run
+	rekorV2EntrySamples = 3
+
+	// how often the checkpoint is polled while waiting for a new entry
+	rekorV2IntegrationPollInterval = time.Second
+
+	// outcomes of waiting for new entries recorded by rekorV2IntegrationCounter
+	integrationOutcomeVisible = "visible"
+	integrationOutcomeTimeout = "timeout"
+	integrationOutcomeError   = "error"
+)
+
+// tileFetcher reads a tiled log through observeRequest so that tile reads
+// are exported to prometheus like any other endpoint. Its methods satisfy
+// the fetcher function types of the Tessera client.
+type tileFetcher struct {
+	host string
+}
+
+func (f tileFetcher) ReadCheckpoint(_ context.Context) ([]byte, error) {
+	return observeRequest(f.host, ReadProberCheck{
+		Endpoint: "/" + layout.CheckpointPath,
+		Method:   GET,
+		Accept:   "text/plain",
+	})
+}
+
+func (f tileFetcher) ReadTile(_ context.Context, level, index uint64, p uint8) ([]byte, error) {
+	return f.readPartialOrFull(p, func(p uint8) ReadProberCheck {
+		slo := fmt.Sprintf("/tile/%d/{index}", level)
+		if p > 0 {
+			slo += ".p/{width}"
+		}
+		return ReadProberCheck{
+			Endpoint:    "/" + layout.TilePath(level, index, p),
+			Method:      GET,
+			Accept:      "application/octet-stream",
+			SLOEndpoint: slo,
+		}
+	})
+}
+
+func (f tileFetcher) ReadEntryBundle(_ context.Context, index uint64, p uint8) ([]byte, error) {
+	return f.readPartialOrFull(p, func(p uint8) ReadProberCheck {
+		slo := "/tile/entries/{index}"
+		if p > 0 {
+			slo += ".p/{width}"
+		}
+		return ReadProberCheck{
+			Endpoint:    "/" + layout.EntriesPath(index, p),
+			Method:      GET,
+			Accept:      "application/octet-stream",
+			SLOEndpoint: slo,
+		}
+	})
+}
+
+// readPartialOrFull fetches a partial resource, falling back to the full
+// resource if the partial one has been replaced as the log grew, as required
+// by the Tessera client.
+func (f tileFetcher) readPartialOrFull(p uint8, check func(p uint8) ReadProberCheck) ([]byte, error) {
+	b, err := observeRequest(f.host, check(p))
+	if p > 0 && errors.Is(err, os.ErrNotExist) {
+		return observeRequest(f.host, check(0))
+	}
+	return b, err
+}
+
+// rekorV2ReadTiles checks that clients can read a Rekor v2 log over the tile
+// API: it verifies the signed checkpoint against the log key from the trusted
+// root, fetches the latest partial and full Merkle tiles, reads entry bundles
+// at random indices and checks their inclusion, and recomputes the root hash
+// of the checkpoint from the tiles.
+func rekorV2ReadTiles(ctx context.Context, s root.Service, trustedRoot *root.TrustedRoot) error {
+	verifier, err := rekorV2NoteVerifier(s.URL, trustedRoot)
+	if err != nil {
+		return err
+	}
+	f := tileFetcher{host: s.URL}
+
+	cp, _, _, err := client.FetchCheckpoint(ctx, f.ReadCheckpoint, verifier, verifier.Name())
+	if err != nil {
+		return verificationFailure(s.URL, reasonCheckpointInvalid, err)
+	}
+	if cp.Size == 0 {
+		return nil
+	}
+
+	// latest tile on the bottom level, partial unless the log size is a
+	// multiple of the tile width, followed by the latest full tile
+	lastTile := (cp.Size - 1) / layout.TileWidth
+	if _, err := f.ReadTile(ctx, 0, lastTile, layout.PartialTileSize(0, lastTile, cp.Size)); err != nil {
+		return fmt.Errorf("fetching latest tile: %w", err)
+	}
+	if fullTiles := cp.Size / layout.TileWidth; fullTiles > 0 {
+		if _, err := f.ReadTile(ctx, 0, fullTiles-1, 0); err != nil {
+			return fmt.Errorf("fetching latest full tile: %w", err)
+		}
+	}
+
+	if err := verifyRootFromTiles(ctx, s.URL, cp, f); err != nil {
+		return err
+	}
+
+	pb, err := client.NewProofBuilder(ctx, cp.Size, f.ReadTile)
+	if err != nil {
+		return fmt.Errorf("creating proof builder: %w", err)
+	}
+	for range rekorV2EntrySamples {
+		index := mrand.Uint64N(cp.Size) // #nosec G404
+		if err := verifyEntryFromBundle(ctx, s.URL, cp, f, pb, index); err != nil {
+			return fmt.Errorf("entry %d: %w", index, err)
+		}
+	}
+	return nil
+}
+
+// verifyRootFromTiles recomputes the root hash of the log from the tiles and
+// compares it to the checkpoint.
+func verifyRootFromTiles(ctx context.Context, host string, cp *log.Checkpoint, f tileFetcher) error {
+	hashes, err := client.FetchRangeNodes(ctx, cp.Size, f.ReadTile)
+	if err != nil {
+		return fmt.Errorf("fetching range nodes: %w", err)
+	}
+	rf := compact.RangeFactory{Hash: rfc6962.DefaultHasher.HashChildren}
+	r, err := rf.NewRange(0, cp.Size, hashes)
+	if err != nil {
+		return fmt.Errorf("creating compact range: %w", err)
+	}
+	rootHash, err := r.GetRootHash(nil)
+	if err != nil {
+		return fmt.Errorf("computing root hash: %w", err)
+	}
+	if !bytes.Equal(rootHash, cp.Hash) {
+		return verificationFailure(host, reasonRootHashMismatch, fmt.Errorf("root hash from tiles %x does not match checkpoint %x at size %d", rootHash, cp.Hash, cp.Size))
+	}
+	return nil
+}
+
+// verifyEntryFromBundle reads the entry at index from its entry bundle and
+// checks that its leaf hash matches the Merkle tiles and is included in the
+// checkpoint.
+func verifyEntryFromBundle(ctx context.Context, host string, cp *log.Checkpoint, f tileFetcher, pb *client.ProofBuilder, index uint64) error {
+	bundle, err := client.GetEntryBundle(ctx, f.ReadEntryBundle, index/layout.EntryBundleWidth, cp.Size)
+	if err != nil {
+		return err
+	}
+	offset := index % layout.EntryBundleWidth
+	if offset >= uint64(len(bundle.Entries)) {
+		return fmt.Errorf("entry bundle has %d entries, expected at least %d", len(bundle.Entries), offset+1)
+	}
+	leafHash := rfc6962.DefaultHasher.HashLeaf(bundle.Entries[offset])
+
+	tileHashes, err := client.FetchLeafHashes(ctx, f.ReadTile, index, 1, cp.Size)
+	if err != nil {
+		return fmt.Errorf("fetching leaf hash: %w", err)
+	}
+	if !bytes.Equal(tileHashes[0], leafHash) {
+		return verificationFailure(host, reasonLeafHashMismatch, fmt.Errorf("leaf hash from tiles %x does not match entry bundle %x", tileHashes[0], leafHash))
+	}
+
+	inclusionProof, err := pb.InclusionProof(ctx, index)
+	if err != nil {
+		return fmt.Errorf("building inclusion proof: %w", err)
+	}
+	if err := proof.VerifyInclusion(rfc6962.DefaultHasher, index, cp.Size, leafHash, inclusionProof, cp.Hash); err != nil {
+		return verificationFailure(host, reasonInclusionProof, err)
+	}
+	return nil
+}
+
+// rekorV2Integration is an entry written to a Rekor v2 log at start, whose
+// integration into the published checkpoint is yet to be checked.
+type rekorV2Integration struct {
+	service root.Service
+	tle     *rekor.TransparencyLogEntry
+	start   time.Time
+}
+
+// rekorV2Integrations collects the entries written to Rekor v2 in a write
+// probe cycle, so that the cycle waits for them to become visible
+// concurrently once all writes are done, rather than for each in turn. The
+// writes add entries sequentially.
+type rekorV2Integrations struct {
+	entries []rekorV2Integration
+}
+
+func (r *rekorV2Integrations) add(s root.Service, tle *rekor.TransparencyLogEntry, start time.Time) {
+	r.entries = append(r.entries, rekorV2Integration{service: s, tle: tle, start: start})
+}
+
+// wait waits for all the collected entries to become visible, each within
+// rekorV2IntegrationTimeout of being written, and clears them.
+func (r *rekorV2Integrations) wait(ctx context.Context, trustedRoot *root.TrustedRoot) error {
+	if len(r.entries) == 0 {
+		return nil
+	}
+	var deadline time.Time
+	for _, e := range r.entries {
+		if d := e.start.Add(rekorV2IntegrationTimeout); d.After(deadline) {
+			deadline = d
+		}
+	}
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	errs := make([]error, len(r.entries))
+	var wg sync.WaitGroup
+	for i, e := range r.entries {
+		wg.Go(func() {
+			if err := rekorV2WaitForEntry(ctx, e.service, e.tle, e.start, trustedRoot); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", e.service.URL, err)
+			}
+		})
+	}
+	wg.Wait()
+	r.entries = nil
+	return errors.Join(errs...)
+}
+
+// rekorV2WaitForEntry polls the published checkpoint of a Rekor v2 log until
+// the entry written at start is visible at its index in the entry bundles,
+// and exports the time this took. It fails if the entry does not become
+// visible within rekorV2IntegrationTimeout or before ctx is done, and records
+// the outcome in rekorV2IntegrationCounter. Until then, it polls again when
+// the checkpoint does not cover the entry yet or fetching from the log fails
+// for any reason other than a missing resource, and the last such failure is
+// returned once the time is up.
+func rekorV2WaitForEntry(ctx context.Context, s root.Service, tle *rekor.TransparencyLogEntry, start time.Time, trustedRoot *root.TrustedRoot) error {
+	outcome := integrationOutcomeError
+	defer func() {
+		rekorV2IntegrationCounter.With(prometheus.Labels{hostLabel: s.URL, outcomeLabel: outcome}).Inc()
+	}()
+	verifier, err := rekorV2NoteVerifier(s.URL, trustedRoot)
+	if err != nil {
+		return err
+	}
+	f := tileFetcher{host: s.URL}
+	index := uint64(tle.GetLogIndex()) // #nosec G115
+
+	var lastErr error
+	for {
+		visible, err := rekorV2EntryVisible(ctx, s.URL, f, verifier, index, tle.GetCanonicalizedBody())
+		switch {
+		case visible:
+			latency := time.Since(start)
+			outcome = integrationOutcomeVisible
+			rekorV2IntegrationLatency.With(prometheus.Labels{hostLabel: s.URL}).Observe(float64(latency.Milliseconds()))
+			Logger.Debugf("entry %d visible in %s after %s", index, s.URL, latency)
+			return nil
+		case err == nil:
+			lastErr = nil
+		case errors.Is(err, errLogFetch) && !errors.Is(err, os.ErrNotExist):
+			// fetches may fail transiently, so they are retried until the
+			// deadline, ignoring the failures caused by reaching it
+			if ctx.Err() == nil {
+				lastErr = err
+				Logger.Debugf("retrying entry %d in %s: %v", index, s.URL, err)
+			}
+		default:
+			return err
+		}
+		if time.Since(start) > rekorV2IntegrationTimeout || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			if lastErr != nil {
+				return fmt.Errorf("entry %d not visible after %s: %w", index, rekorV2IntegrationTimeout, lastErr)
+			}
+			outcome = integrationOutcomeTimeout
+			return fmt.Errorf("entry %d not visible in published checkpoint after %s", index, rekorV2IntegrationTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ctx.Err()
+			}
+		case <-time.After(rekorV2IntegrationPollInterval):
+		}
+	}
+}
+
+// errLogFetch marks failures to fetch the checkpoint, tiles or entry bundles
+// of a log, as opposed to fetched content that fails verification.
+var errLogFetch = errors.New("fetching from log")
+
+// rekorV2EntryVisible reports whether the published checkpoint covers index,
+// in which case the entry bundle must contain body at index and the entry must
+// be included in the checkpoint. Failures to fetch from the log wrap
+// errLogFetch.
+func rekorV2EntryVisible(ctx context.Context, host string, f tileFetcher, verifier sumdb_note.Verifier, index uint64, body []byte) (bool, error) {
+	raw, err := f.ReadCheckpoint(ctx)
+	if err != nil {
+		return false, fmt.Errorf("%w: checkpoint: %w", errLogFetch, err)
+	}
+	cp, _, _, err := log.ParseCheckpoint(raw, verifier.Name(), verifier)
+	if err != nil {
+		return false, verificationFailure(host, reasonCheckpointInvalid, err)
+	}
+	if cp.Size <= index {
+		return false, nil
+	}
+
+	bundle, err := client.GetEntryBundle(ctx, f.ReadEntryBundle, index/layout.EntryBundleWidth, cp.Size)
+	if err != nil {
+		return false, fmt.Errorf("%w: entry bundle: %w", errLogFetch, err)
+	}
+	offset := index % layout.EntryBundleWidth
+	if offset >= uint64(len(bundle.Entries)) {
+		return false, fmt.Errorf("entry bundle has %d entries, expected at least %d", len(bundle.Entries), offset+1)
+	}
+	if !bytes.Equal(bundle.Entries[offset], body) {
+		return false, verificationFailure(host, reasonLeafHashMismatch, fmt.Errorf("entry %d in bundle does not match the written entry", index))
+	}
+
+	pb, err := client.NewProofBuilder(ctx, cp.Size, f.ReadTile)
+	if err != nil {
+		return false, fmt.Errorf("%w: creating proof builder: %w", errLogFetch, err)
+	}
+	inclusionProof, err := pb.InclusionProof(ctx, index)
+	if err != nil {
+		return false, fmt.Errorf("%w: building inclusion proof: %w", errLogFetch, err)
+	}
+	if err := proof.VerifyInclusion(rfc6962.DefaultHasher, index, cp.Size, rfc6962.DefaultHasher.HashLeaf(body), inclusionProof, cp.Hash); err != nil {
+		return false, verificationFailure(host, reasonInclusionProof, err)
+	}
+	return true, nil
+}
+
+// rekorV2NoteVerifier returns a verifier for the checkpoints of the Rekor v2
+// log served at logURL, using the log's key from the trusted root.
+func rekorV2NoteVerifier(logURL string, trustedRoot *root.TrustedRoot) (sumdb_note.Verifier, error) {
+	tlog, err := rekorV2TransparencyLog(logURL, trustedRoot)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(tlog.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing log URL: %w", err)
+	}
+	verifier, err := signature.LoadVerifier(tlog.PublicKey, tlog.SignatureHashFunc)
+	if err != nil {
+		return nil, fmt.Errorf("loading log key: %w", err)
+	}
+	noteVerifier, err := note.NewNoteVerifier(u.Hostname(), verifier)
+	if err != nil {
+		return nil, fmt.Errorf("creating checkpoint verifier: %w", err)
+	}
+	return noteVerifier, nil
+}
+
+// rekorV2TransparencyLog finds the transparency log served at logURL in the
+// trusted root.
+func rekorV2TransparencyLog(logURL string, trustedRoot *root.TrustedRoot) (*root.TransparencyLog, error) {
+	for _, tlog := range trustedRoot.RekorLogs() {
+		if strings.TrimSuffix(tlog.BaseURL, "/") == strings.TrimSuffix(logURL, "/") {
+			return tlog, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find a transparency log for %s in trusted root", logURL)
+}
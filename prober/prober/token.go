@@ -0,0 +1,218 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/sigstore/cosign/v3/pkg/providers"
+	"golang.org/x/oauth2/clientcredentials"
+
+	// Loads OIDC providers
+	"github.com/sigstore/cosign/v3/pkg/providers/all"
+)
+
+// tokenSource is one way for the write probers to obtain identity tokens.
+// Sources other than the one configured by flags are listed in
+// --fulcio-identities, with the same fields as the flags.
+type tokenSource struct {
+	// Name identifies the source in metrics.
+	Name string `json:"name"`
+	// Type is the Fulcio issuer type of the tokens (email, github-workflow,
+	// kubernetes, spiffe or uri), which determines the expected shape of the
+	// issued certificates. If empty, only the generic checks apply, and the
+	// SAN is only derived from claims that identify the type reliably.
+	Type string `json:"type"`
+
+	Local            bool     `json:"local"`
+	TokenFile        string   `json:"tokenFile"`
+	TokenCommand     []string `json:"tokenCommand"`
+	Issuer           string   `json:"issuer"`
+	ClientID         string   `json:"clientID"`
+	ClientSecretFile string   `json:"clientSecretFile"`
+	Audience         string   `json:"audience"`
+}
+
+// defaultTokenSourceName is the name of the token source configured by flags.
+const defaultTokenSourceName = "default"
+
+// flagTokenSource returns the token source configured by flags.
+func flagTokenSource() tokenSource {
+	return tokenSource{
+		Name:             defaultTokenSourceName,
+		Local:            localIssuerURL != "",
+		TokenFile:        idTokenFile,
+		TokenCommand:     idTokenCommand,
+		Issuer:           oidcIssuer,
+		ClientID:         oidcClientID,
+		ClientSecretFile: oidcClientSecretFile,
+		Audience:         oidcAudience,
+	}
+}
+
+// tokenCacheMargin is how long before its expiry a cached token is replaced,
+// so that it does not expire while a probe cycle uses it.
+const tokenCacheMargin = 2 * time.Minute
+
+// cachedToken is a token obtained from a token source and when it expires.
+type cachedToken struct {
+	token  string
+	expiry time.Time
+}
+
+var (
+	tokenCacheMu sync.Mutex
+	// tokenCache holds the last token of the sources that are expensive to
+	// obtain tokens from, by source name, which is unique.
+	tokenCache = map[string]cachedToken{}
+)
+
+// identityToken returns an identity token from the token source configured
+// by flags.
+func identityToken(ctx context.Context) (string, error) {
+	return flagTokenSource().token(ctx)
+}
+
+// token returns an identity token from the first configured source: the
+// embedded issuer if Local is set, TokenFile, which is re-read on every call
+// so that it can be refreshed by another process, TokenCommand, a client
+// credentials grant against Issuer if ClientSecretFile is set, or otherwise
+// the ambient providers cosign knows about, such as GitHub Actions or GCP
+// workload identity. Tokens from TokenCommand and the client credentials
+// grant are reused until shortly before they expire.
+func (s tokenSource) token(ctx context.Context) (string, error) {
+	switch {
+	case s.Local:
+		if embeddedIssuer == nil {
+			return "", fmt.Errorf("token source %s uses the local issuer, but --local-oidc-issuer is not set", s.Name)
+		}
+		return embeddedIssuer.token(s.Audience)
+	case s.TokenFile != "":
+		b, err := os.ReadFile(s.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("reading identity token: %w", err)
+		}
+		return nonEmptyToken(b, s.TokenFile)
+	case len(s.TokenCommand) > 0:
+		return s.cachedToken(ctx, s.commandToken)
+	case s.ClientSecretFile != "":
+		return s.cachedToken(ctx, s.clientCredentialsToken)
+	default:
+		if !all.Enabled(ctx) {
+			return "", fmt.Errorf("no auth provider for fulcio is enabled")
+		}
+		tok, err := providers.Provide(ctx, s.Audience)
+		if err != nil {
+			return "", fmt.Errorf("getting provider: %w", err)
+		}
+		return tok, nil
+	}
+}
+
+// cachedToken returns the cached token of s if it is not about to expire,
+// and otherwise obtains a new one with fetch and caches it until its exp
+// claim. Tokens without an exp claim are not cached.
+func (s tokenSource) cachedToken(ctx context.Context, fetch func(context.Context) (string, error)) (string, error) {
+	tokenCacheMu.Lock()
+	defer tokenCacheMu.Unlock()
+	if c, ok := tokenCache[s.Name]; ok && time.Until(c.expiry) > tokenCacheMargin {
+		return c.token, nil
+	}
+	delete(tokenCache, s.Name)
+	tok, err := fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	if claims, err := parseTokenClaims(tok); err == nil && claims.Expiry != 0 {
+		tokenCache[s.Name] = cachedToken{token: tok, expiry: time.Unix(claims.Expiry, 0)}
+	}
+	return tok, nil
+}
+
+// checkTokenCommand checks that a token command names a program that can be
+// found.
+func checkTokenCommand(command []string) error {
+	if len(command) == 0 || command[0] == "" {
+		return errors.New("token command is empty")
+	}
+	_, err := exec.LookPath(command[0])
+	return err
+}
+
+// commandToken runs TokenCommand, without a shell, and returns its standard
+// output as the token.
+func (s tokenSource) commandToken(ctx context.Context) (string, error) {
+	if err := checkTokenCommand(s.TokenCommand); err != nil {
+		return "", err
+	}
+	args := s.TokenCommand
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec // the command is set by the operator
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nonEmptyToken(stdout.Bytes(), args[0])
+}
+
+// clientCredentialsToken requests a token from the token endpoint of Issuer
+// with the OAuth 2.0 client credentials grant. The ID token is returned if
+// the issuer includes one in the response, otherwise the access token, which
+// issuers that support this grant for Fulcio issue as a JWT.
+func (s tokenSource) clientCredentialsToken(ctx context.Context) (string, error) {
+	secret, err := os.ReadFile(s.ClientSecretFile)
+	if err != nil {
+		return "", fmt.Errorf("reading client secret: %w", err)
+	}
+	ctx = oidc.ClientContext(ctx, retryableClient.StandardClient())
+	provider, err := oidc.NewProvider(ctx, s.Issuer)
+	if err != nil {
+		return "", fmt.Errorf("discovering %s: %w", s.Issuer, err)
+	}
+	config := clientcredentials.Config{
+		ClientID:       s.ClientID,
+		ClientSecret:   strings.TrimSpace(string(secret)),
+		TokenURL:       provider.Endpoint().TokenURL,
+		Scopes:         []string{oidc.ScopeOpenID},
+		EndpointParams: url.Values{"audience": {s.Audience}},
+	}
+	tok, err := config.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("requesting token from %s: %w", config.TokenURL, err)
+	}
+	if idToken, ok := tok.Extra("id_token").(string); ok && idToken != "" {
+		return idToken, nil
+	}
+	return tok.AccessToken, nil
+}
+
+func nonEmptyToken(b []byte, source string) (string, error) {
+	tok := strings.TrimSpace(string(b))
+	if tok == "" {
+		return "", fmt.Errorf("empty identity token from %s", source)
+	}
+	return tok, nil
+}
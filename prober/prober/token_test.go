@@ -0,0 +1,155 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// unsignedToken returns a JWT with the given exp claim, or none if expiry is
+// zero. The token cache does not verify tokens, so it is not signed.
+func unsignedToken(expiry time.Time) string {
+	payload := `{"sub":"prober"}`
+	if !expiry.IsZero() {
+		payload = fmt.Sprintf(`{"sub":"prober","exp":%d}`, expiry.Unix())
+	}
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + "."
+}
+
+func TestCachedToken(t *testing.T) {
+	ctx := context.Background()
+	errFetch := errors.New("fetch failed")
+	fresh := unsignedToken(time.Now().Add(time.Hour))
+	expiring := unsignedToken(time.Now().Add(tokenCacheMargin / 2))
+	noExpiry := unsignedToken(time.Time{})
+	tests := []struct {
+		name string
+		// tokens are returned by consecutive fetches, or errFetch if empty
+		tokens    []string
+		wantFirst string
+		wantLast  string
+		wantErr   bool
+		wantCalls int
+	}{
+		{
+			name:      "reused until expiry",
+			tokens:    []string{fresh, "second"},
+			wantFirst: fresh,
+			wantLast:  fresh,
+			wantCalls: 1,
+		},
+		{
+			name:      "refreshed shortly before expiry",
+			tokens:    []string{expiring, "second"},
+			wantFirst: expiring,
+			wantLast:  "second",
+			wantCalls: 2,
+		},
+		{
+			name:      "not cached without expiry",
+			tokens:    []string{noExpiry, "second"},
+			wantFirst: noExpiry,
+			wantLast:  "second",
+			wantCalls: 2,
+		},
+		{
+			name:      "not cached if not a JWT",
+			tokens:    []string{"first", "second"},
+			wantFirst: "first",
+			wantLast:  "second",
+			wantCalls: 2,
+		},
+		{
+			name:      "fetch error",
+			wantErr:   true,
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { tokenCache = map[string]cachedToken{} })
+			calls := 0
+			fetch := func(context.Context) (string, error) {
+				defer func() { calls++ }()
+				if len(tt.tokens) == 0 {
+					return "", errFetch
+				}
+				return tt.tokens[calls], nil
+			}
+			s := tokenSource{Name: "test"}
+			first, err := s.cachedToken(ctx, fetch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("first cachedToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			last, err := s.cachedToken(ctx, fetch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("second cachedToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if first != tt.wantFirst || last != tt.wantLast {
+				t.Errorf("got tokens %q and %q, want %q and %q", first, last, tt.wantFirst, tt.wantLast)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("got %d fetches, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCachedTokenPerSource(t *testing.T) {
+	t.Cleanup(func() { tokenCache = map[string]cachedToken{} })
+	ctx := context.Background()
+	expiry := time.Now().Add(time.Hour)
+	for _, name := range []string{"first", "second"} {
+		want := unsignedToken(expiry) + name
+		tok, err := tokenSource{Name: name}.cachedToken(ctx, func(context.Context) (string, error) { return want, nil })
+		if err != nil {
+			t.Fatalf("cachedToken(%s) error = %v", name, err)
+		}
+		if tok != want {
+			t.Errorf("cachedToken(%s) = %q, want %q", name, tok, want)
+		}
+	}
+}
+
+func TestCheckTokenCommand(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatalf("finding test binary: %v", err)
+	}
+	tests := []struct {
+		name    string
+		command []string
+		wantErr bool
+	}{
+		{name: "program with arguments", command: []string{self, "an argument with spaces", `"quoted"`}},
+		{name: "program only", command: []string{self}},
+		{name: "empty", wantErr: true},
+		{name: "empty program", command: []string{"", "argument"}, wantErr: true},
+		{name: "missing program", command: []string{"/nonexistent/token-command"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkTokenCommand(tt.command); (err != nil) != tt.wantErr {
+				t.Errorf("checkTokenCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
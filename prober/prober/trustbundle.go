@@ -0,0 +1,156 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	fulciopb "github.com/sigstore/fulcio/pkg/generated/protobuf"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+const (
+	fulcioRootCertEndpoint    = "/api/v1/rootCert"
+	fulcioTrustBundleEndpoint = "/api/v2/trustBundle"
+)
+
+// fulcioTrustBundle is the JSON encoding of the response to
+// /api/v2/trustBundle.
+type fulcioTrustBundle struct {
+	Chains []struct {
+		Certificates []string `json:"certificates"`
+	} `json:"chains"`
+}
+
+// verifyFulcioTrustBundles compares the CA chains Fulcio serves to the Fulcio
+// CAs in the trusted root. Every served chain must be in the trusted root,
+// or clients will fail to verify certificates issued under it, and every
+// active CA in the trusted root must still be served. The HTTP and gRPC trust
+// bundles must also agree with each other. responses maps the Fulcio read
+// endpoints to their response bodies, grpcBundle is nil if gRPC is disabled.
+func verifyFulcioTrustBundles(fulcioService root.Service, responses map[string][]byte, grpcBundle *fulciopb.TrustBundle, trustedRoot *root.TrustedRoot) error {
+	host := fulcioService.URL
+	trusted := map[string]bool{}
+	active := map[string]bool{}
+	now := time.Now()
+	for _, ca := range trustedRoot.FulcioCertificateAuthorities() {
+		fulcioCA, ok := ca.(*root.FulcioCertificateAuthority)
+		if !ok || fulcioCA.URI != host {
+			continue
+		}
+		key := chainKey(append(slices.Clone(fulcioCA.Intermediates), fulcioCA.Root))
+		trusted[key] = true
+		if now.After(fulcioCA.ValidityPeriodStart) && (fulcioCA.ValidityPeriodEnd.IsZero() || now.Before(fulcioCA.ValidityPeriodEnd)) {
+			active[key] = true
+		}
+	}
+
+	var errs []error
+	// checkServed reports served chains missing from the trusted root and,
+	// for a complete trust bundle, active CAs that are not served
+	checkServed := func(source string, served map[string]bool, complete bool) {
+		for key := range served {
+			if !trusted[key] {
+				errs = append(errs, verificationFailure(host, reasonChainNotTrusted, fmt.Errorf("%s serves chain %s", source, key)))
+			}
+		}
+		if !complete {
+			return
+		}
+		for key := range active {
+			if !served[key] {
+				errs = append(errs, verificationFailure(host, reasonActiveCANotServed, fmt.Errorf("%s does not serve chain %s", source, key)))
+			}
+		}
+	}
+
+	var httpChains, grpcChains map[string]bool
+	if body, ok := responses[fulcioTrustBundleEndpoint]; ok {
+		var bundle fulcioTrustBundle
+		if err := json.Unmarshal(body, &bundle); err != nil {
+			errs = append(errs, fmt.Errorf("parsing %s: %w", fulcioTrustBundleEndpoint, err))
+		} else {
+			chains := make([][]string, 0, len(bundle.Chains))
+			for _, c := range bundle.Chains {
+				chains = append(chains, c.Certificates)
+			}
+			if httpChains, err = chainKeys(chains); err != nil {
+				errs = append(errs, fmt.Errorf("parsing %s: %w", fulcioTrustBundleEndpoint, err))
+			} else {
+				checkServed(fulcioTrustBundleEndpoint, httpChains, true)
+			}
+		}
+	}
+	if body, ok := responses[fulcioRootCertEndpoint]; ok {
+		chain, err := cryptoutils.UnmarshalCertificatesFromPEM(body)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parsing %s: %w", fulcioRootCertEndpoint, err))
+		} else if key := chainKey(chain); !active[key] {
+			// the v1 API only serves the chain of the CA issuing certificates
+			errs = append(errs, verificationFailure(host, reasonChainNotTrusted, fmt.Errorf("%s serves chain %s, which is not an active CA in the trusted root", fulcioRootCertEndpoint, key)))
+		}
+	}
+	if grpcBundle != nil {
+		chains := make([][]string, 0, len(grpcBundle.GetChains()))
+		for _, c := range grpcBundle.GetChains() {
+			chains = append(chains, c.GetCertificates())
+		}
+		var err error
+		if grpcChains, err = chainKeys(chains); err != nil {
+			errs = append(errs, fmt.Errorf("parsing GetTrustBundle response: %w", err))
+		} else {
+			checkServed("GetTrustBundle", grpcChains, true)
+		}
+	}
+	if httpChains != nil && grpcChains != nil && !maps.Equal(httpChains, grpcChains) {
+		errs = append(errs, verificationFailure(host, reasonTrustBundleMismatch, fmt.Errorf("%s serves chains %v, GetTrustBundle serves %v",
+			fulcioTrustBundleEndpoint, slices.Sorted(maps.Keys(httpChains)), slices.Sorted(maps.Keys(grpcChains)))))
+	}
+	return errors.Join(errs...)
+}
+
+// chainKeys parses PEM-encoded certificate chains into a set of chain keys.
+func chainKeys(chains [][]string) (map[string]bool, error) {
+	keys := map[string]bool{}
+	for _, c := range chains {
+		chain, err := cryptoutils.UnmarshalCertificatesFromPEM([]byte(strings.Join(c, "\n")))
+		if err != nil {
+			return nil, err
+		}
+		keys[chainKey(chain)] = true
+	}
+	return keys, nil
+}
+
+// chainKey identifies a certificate chain by the SHA-256 fingerprints of its
+// certificates, from the intermediates to the root.
+func chainKey(chain []*x509.Certificate) string {
+	fingerprints := make([]string, 0, len(chain))
+	for _, c := range chain {
+		sum := sha256.Sum256(c.Raw)
+		fingerprints = append(fingerprints, hex.EncodeToString(sum[:]))
+	}
+	return strings.Join(fingerprints, ",")
+}
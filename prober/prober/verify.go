@@ -0,0 +1,317 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/go-openapi/swag/conv"
+	"github.com/prometheus/client_golang/prometheus"
+	common "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	rekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/rekor-tiles/v2/pkg/generated/protobuf"
+	"github.com/sigstore/rekor-tiles/v2/pkg/verify"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/rekor/pkg/types"
+	hashedrekord "github.com/sigstore/rekor/pkg/types/hashedrekord/v0.0.1"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Failure reasons reported by verificationFailureCounter.
+const (
+	reasonMalformedBody       = "malformed_body"
+	reasonBodyMismatch        = "body_mismatch"
+	reasonDigestMismatch      = "digest_mismatch"
+	reasonSignatureMismatch   = "signature_mismatch"
+	reasonVerifierMismatch    = "verifier_mismatch"
+	reasonIntegratedTimeSkew  = "integrated_time_skew"
+	reasonCheckpointInvalid   = "checkpoint_invalid"
+	reasonRootHashMismatch    = "root_hash_mismatch"
+	reasonLeafHashMismatch    = "leaf_hash_mismatch"
+	reasonInclusionProof      = "inclusion_proof"
+	reasonLogIDMismatch       = "log_id_mismatch"
+	reasonSCTMissing          = "sct_missing"
+	reasonSCTUnknownLog       = "sct_unknown_log"
+	reasonSCTInvalid          = "sct_invalid"
+	reasonSCTTimeSkew         = "sct_time_skew"
+	reasonChainInvalid        = "chain_invalid"
+	reasonSANMismatch         = "san_mismatch"
+	reasonIssuerMismatch      = "issuer_mismatch"
+	reasonKeyUsage            = "key_usage"
+	reasonPublicKeyMismatch   = "public_key_mismatch"
+	reasonCertLifetime        = "certificate_lifetime"
+	reasonChainNotTrusted     = "chain_not_in_trusted_root"
+	reasonActiveCANotServed   = "active_ca_not_served"
+	reasonTrustBundleMismatch = "trust_bundle_mismatch"
+	reasonIssuerRemoved       = "issuer_removed"
+	reasonIssuerAdded         = "issuer_added"
+	reasonRekorKeyUnknown     = "rekor_key_unknown"
+	reasonRekorKeyMismatch    = "rekor_key_mismatch"
+	reasonCTKeyMismatch       = "ct_key_mismatch"
+	reasonCTRootMissing       = "ct_root_missing"
+	reasonExtensionMismatch   = "extension_mismatch"
+)
+
+// verificationFailure records a failed check for host under reason and
+// returns err annotated with the reason.
+func verificationFailure(host, reason string, err error) error {
+	verificationFailureCounter.With(prometheus.Labels{hostLabel: host, reasonLabel: reason}).Inc()
+	return fmt.Errorf("%s: %w", reason, err)
+}
+
+// rekorV1VerifyEntryBody checks that the canonicalized body of a Rekor v1
+// entry is the entry that was submitted, and that the entry was integrated
+// close to the local time. Every mismatch is reported separately.
+func rekorV1VerifyEntryBody(ctx context.Context, host string, submitted types.EntryImpl, logEntry models.LogEntryAnon) error {
+	var errs []error
+	if entry, ok := submitted.(*hashedrekord.V001Entry); ok {
+		errs = append(errs, verifyHashedRekordBody(host, entry, logEntry.Body))
+	} else {
+		errs = append(errs, verifyCanonicalBody(ctx, host, submitted, logEntry.Body))
+	}
+	if err := checkTimeSkew("integrated time", time.Unix(conv.Value(logEntry.IntegratedTime), 0), time.Now()); err != nil {
+		errs = append(errs, verificationFailure(host, reasonIntegratedTimeSkew, err))
+	}
+	return errors.Join(errs...)
+}
+
+// verifyHashedRekordBody compares each field of a hashedrekord body to the
+// submitted entry.
+func verifyHashedRekordBody(host string, submitted *hashedrekord.V001Entry, body any) error {
+	got, err := decodeHashedRekordBody(body)
+	if err != nil {
+		return verificationFailure(host, reasonMalformedBody, err)
+	}
+	want := submitted.HashedRekordObj
+
+	var errs []error
+	if conv.Value(got.Data.Hash.Algorithm) != conv.Value(want.Data.Hash.Algorithm) || conv.Value(got.Data.Hash.Value) != conv.Value(want.Data.Hash.Value) {
+		errs = append(errs, verificationFailure(host, reasonDigestMismatch,
+			fmt.Errorf("got %s:%s, want %s:%s", conv.Value(got.Data.Hash.Algorithm), conv.Value(got.Data.Hash.Value), conv.Value(want.Data.Hash.Algorithm), conv.Value(want.Data.Hash.Value))))
+	}
+	if !bytes.Equal(got.Signature.Content, want.Signature.Content) {
+		errs = append(errs, verificationFailure(host, reasonSignatureMismatch,
+			fmt.Errorf("got %s, want %s", got.Signature.Content, want.Signature.Content)))
+	}
+	if !samePEM(got.Signature.PublicKey.Content, want.Signature.PublicKey.Content) {
+		errs = append(errs, verificationFailure(host, reasonVerifierMismatch,
+			fmt.Errorf("got %q, want %q", got.Signature.PublicKey.Content, want.Signature.PublicKey.Content)))
+	}
+	return errors.Join(errs...)
+}
+
+// verifyCanonicalBody compares the body of an entry of any kind to the
+// canonicalization of the submitted entry by its type implementation. The
+// bodies are compared as JSON values rather than bytes, so only changes in
+// content are reported.
+func verifyCanonicalBody(ctx context.Context, host string, submitted types.EntryImpl, body any) error {
+	encoded, ok := body.(string)
+	if !ok {
+		return verificationFailure(host, reasonMalformedBody, fmt.Errorf("unexpected body type %T", body))
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return verificationFailure(host, reasonMalformedBody, fmt.Errorf("decoding body: %w", err))
+	}
+	canonical, err := types.CanonicalizeEntry(ctx, submitted)
+	if err != nil {
+		return fmt.Errorf("canonicalizing submitted entry: %w", err)
+	}
+	var got, want any
+	if err := json.Unmarshal(raw, &got); err != nil {
+		return verificationFailure(host, reasonMalformedBody, fmt.Errorf("parsing body: %w", err))
+	}
+	if err := json.Unmarshal(canonical, &want); err != nil {
+		return fmt.Errorf("parsing canonicalized entry: %w", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		return verificationFailure(host, reasonBodyMismatch, fmt.Errorf("got %s, want %s", raw, canonical))
+	}
+	return nil
+}
+
+// rekorV2VerifyEntry verifies a transparency log entry returned by Rekor v2:
+// the log ID must match the log in the trusted root, the checkpoint must be
+// signed by that log's key, the inclusion proof must show the leaf hash of the
+// canonicalized body is in the checkpoint, and the body must be the
+// hashedrekord or DSSE entry that was submitted.
+func rekorV2VerifyEntry(host string, tle *rekor.TransparencyLogEntry, submitted *protobuf.CreateEntryRequest, trustedRoot *root.TrustedRoot) error {
+	tlog, err := rekorV2TransparencyLog(host, trustedRoot)
+	if err != nil {
+		return err
+	}
+	noteVerifier, err := rekorV2NoteVerifier(host, trustedRoot)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if got := tle.GetLogId().GetKeyId(); !bytes.Equal(got, tlog.ID) {
+		errs = append(errs, verificationFailure(host, reasonLogIDMismatch, fmt.Errorf("got %x, want %x", got, tlog.ID)))
+	}
+
+	inclusionProof := tle.GetInclusionProof()
+	cp, err := verify.VerifyCheckpoint(inclusionProof.GetCheckpoint().GetEnvelope(), noteVerifier)
+	if err != nil {
+		errs = append(errs, verificationFailure(host, reasonCheckpointInvalid, err))
+	} else {
+		switch {
+		case inclusionProof.GetLogIndex() != tle.GetLogIndex():
+			err = fmt.Errorf("proof log index %d does not match entry log index %d", inclusionProof.GetLogIndex(), tle.GetLogIndex())
+		case uint64(inclusionProof.GetTreeSize()) != cp.Size: // #nosec G115
+			err = fmt.Errorf("proof tree size %d does not match checkpoint size %d", inclusionProof.GetTreeSize(), cp.Size)
+		case !bytes.Equal(inclusionProof.GetRootHash(), cp.Hash):
+			err = fmt.Errorf("proof root hash %x does not match checkpoint root hash %x", inclusionProof.GetRootHash(), cp.Hash)
+		default:
+			// re-hashes the canonicalized body to compute the leaf hash
+			err = verify.VerifyInclusionProof(tle, cp)
+		}
+		if err != nil {
+			errs = append(errs, verificationFailure(host, reasonInclusionProof, err))
+		}
+	}
+
+	body := protobuf.Entry{}
+	if err := protojson.Unmarshal(tle.GetCanonicalizedBody(), &body); err != nil {
+		errs = append(errs, verificationFailure(host, reasonMalformedBody, err))
+		return errors.Join(errs...)
+	}
+	kind := rekorV2KindHashedRekord
+	if submitted.GetDsseRequestV002() != nil {
+		kind = rekorV2KindDSSE
+	}
+	if tle.GetKindVersion().GetKind() != kind || tle.GetKindVersion().GetVersion() != rekorV2KindVersions[kind] {
+		errs = append(errs, verificationFailure(host, reasonMalformedBody, fmt.Errorf("unexpected entry kind %s %s, want %s %s", tle.GetKindVersion().GetKind(), tle.GetKindVersion().GetVersion(), kind, rekorV2KindVersions[kind])))
+		return errors.Join(errs...)
+	}
+	if kind == rekorV2KindDSSE {
+		errs = append(errs, verifyDSSEV2Body(host, &body, submitted.GetDsseRequestV002()))
+	} else {
+		errs = append(errs, verifyHashedRekordV2Body(host, &body, submitted.GetHashedRekordRequestV002()))
+	}
+	return errors.Join(errs...)
+}
+
+// verifyHashedRekordV2Body checks that a Rekor v2 entry body is the
+// hashedrekord that was submitted.
+func verifyHashedRekordV2Body(host string, body *protobuf.Entry, submitted *protobuf.HashedRekordRequestV002) error {
+	got := body.GetSpec().GetHashedRekordV002()
+	if got == nil {
+		return verificationFailure(host, reasonMalformedBody, errors.New("missing hashedrekord spec"))
+	}
+	var errs []error
+	if !bytes.Equal(got.GetData().GetDigest(), submitted.GetDigest()) {
+		errs = append(errs, verificationFailure(host, reasonDigestMismatch, fmt.Errorf("got %x, want %x", got.GetData().GetDigest(), submitted.GetDigest())))
+	}
+	if !bytes.Equal(got.GetSignature().GetContent(), submitted.GetSignature().GetContent()) {
+		errs = append(errs, verificationFailure(host, reasonSignatureMismatch, fmt.Errorf("got %x, want %x", got.GetSignature().GetContent(), submitted.GetSignature().GetContent())))
+	}
+	if !proto.Equal(got.GetSignature().GetVerifier(), submitted.GetSignature().GetVerifier()) {
+		errs = append(errs, verificationFailure(host, reasonVerifierMismatch, errors.New("entry verifier does not match submitted verifier")))
+	}
+	return errors.Join(errs...)
+}
+
+// verifyDSSEV2Body checks that a Rekor v2 entry body logs the DSSE envelope
+// that was submitted: the SHA-256 hash of its payload, and its only
+// signature with the submitted verifier.
+func verifyDSSEV2Body(host string, body *protobuf.Entry, submitted *protobuf.DSSERequestV002) error {
+	got := body.GetSpec().GetDsseV002()
+	if got == nil {
+		return verificationFailure(host, reasonMalformedBody, errors.New("missing dsse spec"))
+	}
+	var errs []error
+	payloadHash := sha256.Sum256(submitted.GetEnvelope().GetPayload())
+	if got.GetPayloadHash().GetAlgorithm() != common.HashAlgorithm_SHA2_256 || !bytes.Equal(got.GetPayloadHash().GetDigest(), payloadHash[:]) {
+		errs = append(errs, verificationFailure(host, reasonDigestMismatch, fmt.Errorf("got %s %x, want %s %x", got.GetPayloadHash().GetAlgorithm(), got.GetPayloadHash().GetDigest(), common.HashAlgorithm_SHA2_256, payloadHash)))
+	}
+	if len(got.GetSignatures()) != 1 {
+		errs = append(errs, verificationFailure(host, reasonSignatureMismatch, fmt.Errorf("got %d signatures, want 1", len(got.GetSignatures()))))
+		return errors.Join(errs...)
+	}
+	want := submitted.GetEnvelope().GetSignatures()[0].GetSig()
+	if !bytes.Equal(got.GetSignatures()[0].GetContent(), want) {
+		errs = append(errs, verificationFailure(host, reasonSignatureMismatch, fmt.Errorf("got %x, want %x", got.GetSignatures()[0].GetContent(), want)))
+	}
+	if !proto.Equal(got.GetSignatures()[0].GetVerifier(), submitted.GetVerifiers()[0]) {
+		errs = append(errs, verificationFailure(host, reasonVerifierMismatch, errors.New("entry verifier does not match submitted verifier")))
+	}
+	return errors.Join(errs...)
+}
+
+// decodeHashedRekordBody decodes the base64-encoded canonicalized body of a
+// Rekor v1 log entry into a hashedrekord v0.0.1 spec.
+func decodeHashedRekordBody(body any) (*models.HashedrekordV001Schema, error) {
+	encoded, ok := body.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected body type %T", body)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decoding body: %w", err)
+	}
+	var pe models.Hashedrekord
+	if err := json.Unmarshal(raw, &pe); err != nil {
+		return nil, fmt.Errorf("parsing body: %w", err)
+	}
+	if v := conv.Value(pe.APIVersion); v != hashedrekord.APIVERSION {
+		return nil, fmt.Errorf("unexpected hashedrekord version %s", v)
+	}
+	specBytes, err := json.Marshal(pe.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling spec: %w", err)
+	}
+	var spec models.HashedrekordV001Schema
+	if err := json.Unmarshal(specBytes, &spec); err != nil {
+		return nil, fmt.Errorf("parsing spec: %w", err)
+	}
+	if spec.Data == nil || spec.Data.Hash == nil || spec.Signature == nil || spec.Signature.PublicKey == nil {
+		return nil, errors.New("missing required fields in spec")
+	}
+	return &spec, nil
+}
+
+// checkTimeSkew checks that a timestamp issued by a service, such as an
+// entry's integrated time, is within clockSkew of now.
+func checkTimeSkew(what string, t, now time.Time) error {
+	skew := now.Sub(t)
+	if skew.Abs() > clockSkew {
+		return fmt.Errorf("%s %s is %s away from local time %s, more than the allowed %s",
+			what, t.UTC().Format(time.RFC3339), skew, now.UTC().Format(time.RFC3339), clockSkew)
+	}
+	return nil
+}
+
+// samePEM compares the DER contents of two PEM blocks, so that differences in
+// encoding such as line endings are not reported as mismatches.
+func samePEM(a, b []byte) bool {
+	blockA, _ := pem.Decode(a)
+	blockB, _ := pem.Decode(b)
+	if blockA == nil || blockB == nil {
+		return bytes.Equal(a, b)
+	}
+	return blockA.Type == blockB.Type && bytes.Equal(blockA.Bytes, blockB.Bytes)
+}
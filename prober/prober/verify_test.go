@@ -0,0 +1,216 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/swag/conv"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/rekor/pkg/types"
+	hashedrekord "github.com/sigstore/rekor/pkg/types/hashedrekord/v0.0.1"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// hashedRekordBody encodes a hashedrekord spec as the body of a Rekor v1 log
+// entry.
+func hashedRekordBody(t *testing.T, spec models.HashedrekordV001Schema) string {
+	t.Helper()
+	b, err := json.Marshal(&models.Hashedrekord{APIVersion: conv.Pointer(hashedrekord.APIVERSION), Spec: spec})
+	if err != nil {
+		t.Fatalf("marshalling body: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func TestVerifyHashedRekordBody(t *testing.T) {
+	key, err := newSigningKey("ecdsa-sha2-256-nistp256")
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	submitted, err := rekorV1EntryRequest(nil, key)
+	if err != nil {
+		t.Fatalf("building entry: %v", err)
+	}
+	other, err := newSigningKey("ecdsa-sha2-256-nistp256")
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	otherPEM, err := cryptoutils.MarshalPublicKeyToPEM(other.Public())
+	if err != nil {
+		t.Fatalf("marshalling key: %v", err)
+	}
+
+	// modified returns the body of the submitted entry after modify changes
+	// a copy of its spec
+	modified := func(modify func(*models.HashedrekordV001Schema)) string {
+		want := submitted.HashedRekordObj
+		spec := models.HashedrekordV001Schema{
+			Data: &models.HashedrekordV001SchemaData{Hash: &models.HashedrekordV001SchemaDataHash{
+				Algorithm: conv.Pointer(conv.Value(want.Data.Hash.Algorithm)),
+				Value:     conv.Pointer(conv.Value(want.Data.Hash.Value)),
+			}},
+			Signature: &models.HashedrekordV001SchemaSignature{
+				Content:   bytes.Clone(want.Signature.Content),
+				PublicKey: &models.HashedrekordV001SchemaSignaturePublicKey{Content: bytes.Clone(want.Signature.PublicKey.Content)},
+			},
+		}
+		modify(&spec)
+		return hashedRekordBody(t, spec)
+	}
+
+	tests := []struct {
+		name       string
+		body       any
+		wantReason string
+	}{
+		{name: "same entry", body: modified(func(*models.HashedrekordV001Schema) {})},
+		{name: "verifier with CRLF line endings", body: modified(func(s *models.HashedrekordV001Schema) {
+			s.Signature.PublicKey.Content = strfmt.Base64(strings.ReplaceAll(string(s.Signature.PublicKey.Content), "\n", "\r\n"))
+		})},
+		{name: "other digest algorithm", body: modified(func(s *models.HashedrekordV001Schema) {
+			s.Data.Hash.Algorithm = conv.Pointer(models.HashedrekordV001SchemaDataHashAlgorithmSha512)
+		}), wantReason: reasonDigestMismatch},
+		{name: "other digest", body: modified(func(s *models.HashedrekordV001Schema) {
+			s.Data.Hash.Value = conv.Pointer(strings.Repeat("0", 64))
+		}), wantReason: reasonDigestMismatch},
+		{name: "other signature", body: modified(func(s *models.HashedrekordV001Schema) {
+			s.Signature.Content = strfmt.Base64("signature")
+		}), wantReason: reasonSignatureMismatch},
+		{name: "other verifier", body: modified(func(s *models.HashedrekordV001Schema) {
+			s.Signature.PublicKey.Content = strfmt.Base64(otherPEM)
+		}), wantReason: reasonVerifierMismatch},
+		{name: "missing signature", body: modified(func(s *models.HashedrekordV001Schema) {
+			s.Signature = nil
+		}), wantReason: reasonMalformedBody},
+		{name: "not base64", body: "not base64!", wantReason: reasonMalformedBody},
+		{name: "not a string", body: 42, wantReason: reasonMalformedBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyHashedRekordBody("https://rekor.example.com", submitted, tt.body)
+			checkReason(t, err, tt.wantReason)
+		})
+	}
+}
+
+func TestVerifyCanonicalBody(t *testing.T) {
+	ctx := context.Background()
+	key, err := newSigningKey("ecdsa-sha2-256-nistp256")
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	for _, kind := range []string{rekorV1KindRekord, rekorV1KindIntoto, rekorV1KindDSSE} {
+		t.Run(kind, func(t *testing.T) {
+			proposed, _, err := rekorV1NewEntry(ctx, kind, nil, key)
+			if err != nil {
+				t.Fatalf("building entry: %v", err)
+			}
+			// canonicalizing a rekord entry consumes its artifact, so every
+			// canonicalization needs its own implementation of the entry
+			newEntry := func() types.EntryImpl {
+				entry, err := types.CreateVersionedEntry(proposed)
+				if err != nil {
+					t.Fatalf("creating entry: %v", err)
+				}
+				return entry
+			}
+			canonical, err := types.CanonicalizeEntry(ctx, newEntry())
+			if err != nil {
+				t.Fatalf("canonicalizing entry: %v", err)
+			}
+			var fields map[string]any
+			if err := json.Unmarshal(canonical, &fields); err != nil {
+				t.Fatalf("parsing canonicalized entry: %v", err)
+			}
+			// the same content, encoded with other whitespace and key order
+			indented, err := json.MarshalIndent(fields, "", "  ")
+			if err != nil {
+				t.Fatalf("re-encoding entry: %v", err)
+			}
+			fields["apiVersion"] = "9.9.9"
+			changed, err := json.Marshal(fields)
+			if err != nil {
+				t.Fatalf("re-encoding entry: %v", err)
+			}
+
+			tests := []struct {
+				name       string
+				body       any
+				wantReason string
+			}{
+				{name: "canonical body", body: base64.StdEncoding.EncodeToString(canonical)},
+				{name: "re-encoded body", body: base64.StdEncoding.EncodeToString(indented)},
+				{name: "changed body", body: base64.StdEncoding.EncodeToString(changed), wantReason: reasonBodyMismatch},
+				{name: "not JSON", body: base64.StdEncoding.EncodeToString([]byte("entry")), wantReason: reasonMalformedBody},
+				{name: "not base64", body: "not base64!", wantReason: reasonMalformedBody},
+				{name: "not a string", body: 42, wantReason: reasonMalformedBody},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					err := verifyCanonicalBody(ctx, "https://rekor.example.com", newEntry(), tt.body)
+					checkReason(t, err, tt.wantReason)
+				})
+			}
+		})
+	}
+}
+
+func TestCheckTimeSkew(t *testing.T) {
+	oldClockSkew := clockSkew
+	clockSkew = time.Minute
+	t.Cleanup(func() { clockSkew = oldClockSkew })
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		t       time.Time
+		wantErr bool
+	}{
+		{name: "now", t: now},
+		{name: "within skew before", t: now.Add(-clockSkew + time.Second)},
+		{name: "within skew after", t: now.Add(clockSkew - time.Second)},
+		{name: "too old", t: now.Add(-clockSkew - time.Second), wantErr: true},
+		{name: "in the future", t: now.Add(clockSkew + time.Second), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkTimeSkew("timestamp", tt.t, now); (err != nil) != tt.wantErr {
+				t.Errorf("checkTimeSkew() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// checkReason checks that err reports a single failure reason, or none if
+// wantReason is empty.
+func checkReason(t *testing.T, err error, wantReason string) {
+	t.Helper()
+	switch {
+	case wantReason == "" && err != nil:
+		t.Errorf("got error %v, want none", err)
+	case wantReason != "" && err == nil:
+		t.Errorf("got no error, want %s", wantReason)
+	case wantReason != "" && !strings.HasPrefix(err.Error(), wantReason+":"):
+		t.Errorf("got error %v, want %s", err, wantReason)
+	}
+}
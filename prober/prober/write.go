@@ -18,10 +18,10 @@ import (
 	"bytes"
 	"context"
 	"crypto"
-	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
@@ -29,6 +29,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/digitorus/timestamp"
@@ -36,24 +38,21 @@ import (
 	"github.com/go-openapi/swag/conv"
 	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/protojson"
 
 	retryablehttp "github.com/hashicorp/go-retryablehttp"
 
 	"github.com/sigstore/cosign/v3/pkg/cosign"
-	"github.com/sigstore/cosign/v3/pkg/providers"
-	common "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	fulciopb "github.com/sigstore/fulcio/pkg/generated/protobuf"
 	rekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
 	"github.com/sigstore/rekor-tiles/v2/pkg/generated/protobuf"
 	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/rekor/pkg/types"
 	hashedrekord "github.com/sigstore/rekor/pkg/types/hashedrekord/v0.0.1"
 	"github.com/sigstore/sigstore-go/pkg/root"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
 	"github.com/sigstore/sigstore/pkg/oauthflow"
-	"github.com/sigstore/sigstore/pkg/signature"
-
-	// Loads OIDC providers
-	"github.com/sigstore/cosign/v3/pkg/providers/all"
 )
 
 const (
@@ -64,8 +63,50 @@ const (
 	fulcioLegacyEndpoint = "/api/v1/signingCert"
 	rekorEndpoint        = "/api/v1/log/entries"
 	rekorV2Endpoint      = "/api/v2/log/entries"
+
+	// write probe names used as metric labels
+	fulcioWriteProbe     = "fulcio_write"
+	fulcioGrpcWriteProbe = "fulcio_grpc_write"
+	rekorV1WriteProbe    = "rekor_v1_write"
+	rekorV2WriteProbe    = "rekor_v2_write"
+	tsaWriteProbe        = "tsa_write"
+
+	// key-only write probe names, for Rekor entries with the public key of
+	// the probe key rather than a Fulcio certificate
+	rekorV1KeyOnlyWriteProbe = "rekor_v1_key_only_write"
+	rekorV2KeyOnlyWriteProbe = "rekor_v2_key_only_write"
+
+	// writePolicyAll requires every service to accept and verify a write,
+	// writePolicyAny only requires one of them to.
+	writePolicyAll = "all"
+	writePolicyAny = "any"
+
+	// fulcioRequestModePublicKey requests a certificate for a public key
+	// with a signed subject as proof of possession,
+	// fulcioRequestModeCSR with a PKCS#10 certificate signing request, and
+	// fulcioRequestModeRotate alternates between them on each cycle.
+	fulcioRequestModePublicKey = "public-key"
+	fulcioRequestModeCSR       = "csr"
+	fulcioRequestModeRotate    = "rotate"
 )
 
+// fulcioRequestCount counts the write probe cycles, to alternate request
+// modes.
+var fulcioRequestCount int
+
+// nextFulcioRequestMode returns the request mode for the next write probe
+// cycle, used for both the HTTP and gRPC APIs.
+func nextFulcioRequestMode() string {
+	if fulcioRequestMode != fulcioRequestModeRotate {
+		return fulcioRequestMode
+	}
+	fulcioRequestCount++
+	if fulcioRequestCount%2 == 0 {
+		return fulcioRequestModeCSR
+	}
+	return fulcioRequestModePublicKey
+}
+
 func setHeaders(req *retryablehttp.Request, token string, rpc ReadProberCheck) {
 	if token != "" {
 		// Set the authorization header to our OIDC bearer token.
@@ -92,15 +133,16 @@ func setHeaders(req *retryablehttp.Request, token string, rpc ReadProberCheck) {
 }
 
 // fulcioWriteLegacyEndpoint tests the /api/v1/signingCert write endpoint for Fulcio.
-func fulcioWriteLegacyEndpoint(ctx context.Context, priv *ecdsa.PrivateKey, fulcioService root.Service) (*x509.Certificate, error) {
-	if !all.Enabled(ctx) {
-		return nil, fmt.Errorf("no auth provider for fulcio is enabled")
+func fulcioWriteLegacyEndpoint(ctx context.Context, key *signingKey, fulcioService root.Service, trustedRoot *root.TrustedRoot) (*x509.Certificate, error) {
+	tok, err := identityToken(ctx)
+	if err != nil {
+		return nil, err
 	}
-	tok, err := providers.Provide(ctx, "sigstore")
+	claims, err := parseTokenClaims(tok)
 	if err != nil {
-		return nil, fmt.Errorf("getting provider: %w", err)
+		return nil, err
 	}
-	b, err := legacyCertificateRequest(ctx, tok, priv)
+	b, err := legacyCertificateRequest(ctx, tok, key)
 	if err != nil {
 		return nil, fmt.Errorf("certificate response: %w", err)
 	}
@@ -135,23 +177,20 @@ func fulcioWriteLegacyEndpoint(ctx context.Context, priv *ecdsa.PrivateKey, fulc
 		Logger.Errorf("error reading response from Fulcio: %v", err)
 		return nil, err
 	}
-	certBlock, chainPEM := pem.Decode(responseBody)
-	if certBlock == nil || chainPEM == nil {
-		Logger.Errorf("did not find expected certificates")
-	}
-	intermediateBlock, rootPEM := pem.Decode(chainPEM)
-	if intermediateBlock == nil || rootPEM == nil {
-		Logger.Errorf("did not find expected certificate chain in response from Fulcio")
-	}
-	certPEM := pem.EncodeToMemory(certBlock)
-	cert, err := cryptoutils.UnmarshalCertificatesFromPEM(certPEM)
+	activeCA, err := activeFulcioCA(fulcioService, trustedRoot)
 	if err != nil {
-		Logger.Errorf("error unmarshalling leaf certificate from Fulcio: %v", err)
 		return nil, err
 	}
-	if len(cert) != 1 {
-		Logger.Errorf("unexpected number of certificates after unmarshalling got %d, expected 1", len(cert))
-		return nil, err
+	// the response is the leaf certificate followed by the chain
+	cert, err := cryptoutils.UnmarshalCertificatesFromPEM(responseBody)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling certificate chain from Fulcio: %w", err)
+	}
+	if expected := len(activeCA.Intermediates) + 2; len(cert) != expected { // leaf + intermediates + root
+		return nil, fmt.Errorf("unexpected number of certificates in response from Fulcio, got %d, expected %d", len(cert), expected)
+	}
+	if err := verifyFulcioCertificate(fulcioService.URL, cert[0], activeCA, key.Public(), "", claims); err != nil {
+		return nil, fmt.Errorf("verifying certificate: %w", err)
 	}
 
 	// Export data to prometheus
@@ -159,16 +198,35 @@ func fulcioWriteLegacyEndpoint(ctx context.Context, priv *ecdsa.PrivateKey, fulc
 	return cert[0], nil
 }
 
-// fulcioWriteEndpoint tests the /api/v2/signingCert write endpoint for Fulcio.
-func fulcioWriteEndpoint(ctx context.Context, priv *ecdsa.PrivateKey, fulcioService root.Service, trustedRoot *root.TrustedRoot) (*x509.Certificate, error) {
-	if !all.Enabled(ctx) {
-		return nil, fmt.Errorf("no auth provider for fulcio is enabled")
+// fulcioWriteEndpoint tests the /api/v2/signingCert write endpoint for Fulcio
+// with a token from source, requesting the certificate with either a public
+// key or a CSR as selected by --fulcio-request-mode.
+func fulcioWriteEndpoint(ctx context.Context, key *signingKey, mode string, source tokenSource, fulcioService root.Service, trustedRoot *root.TrustedRoot) (_ *x509.Certificate, err error) {
+	issuer := issuerUnknown
+	defer func() {
+		writeProbeCounter.With(prometheus.Labels{
+			probeLabel:     fulcioWriteProbe + "_" + strings.ReplaceAll(mode, "-", "_"),
+			hostLabel:      fulcioService.URL,
+			algorithmLabel: key.name,
+			successLabel:   strconv.FormatBool(err == nil),
+		}).Inc()
+		fulcioIdentityWriteCounter.With(prometheus.Labels{
+			hostLabel:     fulcioService.URL,
+			identityLabel: source.Name,
+			issuerLabel:   issuer,
+			successLabel:  strconv.FormatBool(err == nil),
+		}).Inc()
+	}()
+	tok, err := source.token(ctx)
+	if err != nil {
+		return nil, err
 	}
-	tok, err := providers.Provide(ctx, "sigstore")
+	claims, err := parseTokenClaims(tok)
 	if err != nil {
-		return nil, fmt.Errorf("getting provider: %w", err)
+		return nil, err
 	}
-	b, err := certificateRequest(ctx, tok, priv)
+	issuer = claims.Issuer
+	b, err := certificateRequestForMode(ctx, tok, key, mode)
 	if err != nil {
 		return nil, fmt.Errorf("certificate response: %w", err)
 	}
@@ -209,47 +267,128 @@ func fulcioWriteEndpoint(ctx context.Context, priv *ecdsa.PrivateKey, fulcioServ
 		return nil, err
 	}
 
-	var activeCA *root.FulcioCertificateAuthority
-	now := time.Now()
-	for _, ca := range trustedRoot.FulcioCertificateAuthorities() {
-		if fulcioCA, ok := ca.(*root.FulcioCertificateAuthority); ok {
-			isActive := now.After(fulcioCA.ValidityPeriodStart) && (fulcioCA.ValidityPeriodEnd.IsZero() || now.Before(fulcioCA.ValidityPeriodEnd))
-			if fulcioCA.URI == fulcioService.URL && isActive {
-				activeCA = fulcioCA
-				break
-			}
-		}
-	}
-	if activeCA == nil {
-		return nil, fmt.Errorf("could not find an active Fulcio CA for URI %s in trusted root", fulcioService.URL)
-	}
-
-	fulcioExpectedCertCount := len(activeCA.Intermediates) + 2 // leaf + intermediates + root
-	fulcioRespCertCount := len(fulcioResp.CertificatesWithSct.CertificateChain.Certificates)
-	if fulcioRespCertCount != fulcioExpectedCertCount {
-		err := fmt.Errorf("unexpected number of certificates, got %d, expected %d", fulcioRespCertCount, fulcioExpectedCertCount)
+	activeCA, err := activeFulcioCA(fulcioService, trustedRoot)
+	if err != nil {
 		return nil, err
 	}
 
-	cert, err := cryptoutils.UnmarshalCertificatesFromPEM([]byte(fulcioResp.CertificatesWithSct.CertificateChain.Certificates[0]))
+	chainPEM, detachedSCT, err := fulcioResp.certificateChain()
 	if err != nil {
-		Logger.Errorf("error unmarshalling leaf certificate from Fulcio: %v", err)
 		return nil, err
 	}
-	if len(cert) != 1 {
-		Logger.Errorf("unexpected number of certificates after unmarshalling got %d, expected 1", len(cert))
+	cert, err := verifyFulcioChain(fulcioService.URL, chainPEM, detachedSCT, activeCA, key.Public(), source.Type, claims, trustedRoot)
+	if err != nil {
 		return nil, err
 	}
 
 	// Export data to prometheus
 	exportDataToPrometheus(resp, fulcioService.URL, endpoint, POST, latency)
+	return cert, nil
+}
+
+// fulcioGrpcWriteEndpoint tests CreateSigningCertificate on the Fulcio gRPC
+// API with a token from source, sending the same request as
+// fulcioWriteEndpoint and validating the returned certificate the same way,
+// so that differences between the gRPC server and the HTTP gateway in front
+// of it are caught.
+func fulcioGrpcWriteEndpoint(ctx context.Context, key *signingKey, mode string, source tokenSource, fulcioGrpcClient fulciopb.CAClient, fulcioGrpcURL string, fulcioService root.Service, trustedRoot *root.TrustedRoot) (err error) {
+	host := "grpc://" + fulcioGrpcURL
+	defer func() {
+		writeProbeCounter.With(prometheus.Labels{
+			probeLabel:     fulcioGrpcWriteProbe + "_" + strings.ReplaceAll(mode, "-", "_"),
+			hostLabel:      host,
+			algorithmLabel: key.name,
+			successLabel:   strconv.FormatBool(err == nil),
+		}).Inc()
+	}()
+	tok, err := source.token(ctx)
+	if err != nil {
+		return err
+	}
+	claims, err := parseTokenClaims(tok)
+	if err != nil {
+		return err
+	}
+	b, err := certificateRequestForMode(ctx, tok, key, mode)
+	if err != nil {
+		return fmt.Errorf("certificate request: %w", err)
+	}
+	// the HTTP request body is the JSON encoding of the gRPC request
+	var req fulciopb.CreateSigningCertificateRequest
+	if err := protojson.Unmarshal(b, &req); err != nil {
+		return fmt.Errorf("converting certificate request: %w", err)
+	}
+	req.Credentials = &fulciopb.Credentials{
+		Credentials: &fulciopb.Credentials_OidcIdentityToken{OidcIdentityToken: tok},
+	}
+
+	t := time.Now()
+	resp, err := fulcioGrpcClient.CreateSigningCertificate(ctx, &req)
+	latency := time.Since(t).Milliseconds()
+	exportGrpcDataToPrometheus(status.Code(err), host, "CreateSigningCertificate", POST, latency)
+	if err != nil {
+		return fmt.Errorf("requesting certificate: %w", err)
+	}
+
+	activeCA, err := activeFulcioCA(fulcioService, trustedRoot)
+	if err != nil {
+		return err
+	}
+	var chainPEM []string
+	var detachedSCT []byte
+	switch {
+	case resp.GetSignedCertificateEmbeddedSct() != nil:
+		chainPEM = resp.GetSignedCertificateEmbeddedSct().GetChain().GetCertificates()
+	case resp.GetSignedCertificateDetachedSct() != nil:
+		chainPEM = resp.GetSignedCertificateDetachedSct().GetChain().GetCertificates()
+		detachedSCT = resp.GetSignedCertificateDetachedSct().GetSignedCertificateTimestamp()
+	default:
+		return errors.New("response has neither an embedded nor a detached SCT certificate")
+	}
+	_, err = verifyFulcioChain(host, chainPEM, detachedSCT, activeCA, key.Public(), source.Type, claims, trustedRoot)
+	return err
+}
+
+// verifyFulcioChain parses the PEM-encoded certificate chain returned by
+// Fulcio for a token of identityType and validates the leaf certificate, its
+// SCT, and the identity shape for the type. The HTTP and gRPC write probers
+// share it so that their certificates are validated the same way.
+func verifyFulcioChain(host string, chainPEM []string, detachedSCT []byte, activeCA *root.FulcioCertificateAuthority, submitted crypto.PublicKey, identityType string, claims *tokenClaims, trustedRoot *root.TrustedRoot) (*x509.Certificate, error) {
+	fulcioExpectedCertCount := len(activeCA.Intermediates) + 2 // leaf + intermediates + root
+	if len(chainPEM) != fulcioExpectedCertCount {
+		return nil, fmt.Errorf("unexpected number of certificates, got %d, expected %d", len(chainPEM), fulcioExpectedCertCount)
+	}
+
+	cert, err := cryptoutils.UnmarshalCertificatesFromPEM([]byte(chainPEM[0]))
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling leaf certificate from Fulcio: %w", err)
+	}
+	if len(cert) != 1 {
+		return nil, fmt.Errorf("unexpected number of certificates after unmarshalling got %d, expected 1", len(cert))
+	}
+	issuer, err := cryptoutils.UnmarshalCertificatesFromPEM([]byte(chainPEM[1]))
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling issuing certificate from Fulcio: %w", err)
+	}
+	if len(issuer) != 1 {
+		return nil, fmt.Errorf("unexpected number of issuing certificates after unmarshalling got %d, expected 1", len(issuer))
+	}
+	if err := verifyFulcioCertificate(host, cert[0], activeCA, submitted, identityType, claims); err != nil {
+		return nil, fmt.Errorf("verifying certificate: %w", err)
+	}
+	if err := verifyFulcioSCTs(host, cert[0], issuer[0], detachedSCT, trustedRoot); err != nil {
+		return nil, fmt.Errorf("verifying SCT: %w", err)
+	}
+	if err := checkIdentityShape(host, cert[0], identityType, claims); err != nil {
+		return nil, err
+	}
 	return cert[0], nil
 }
 
-func makeRekorV1Request(cert *x509.Certificate, priv *ecdsa.PrivateKey, hostPath string) (*http.Response, int64, error) {
-	body, err := rekorV1EntryRequest(cert, priv)
+func makeRekorV1Request(entry models.ProposedEntry, hostPath string) (*http.Response, int64, error) {
+	body, err := json.Marshal(entry)
 	if err != nil {
-		return nil, -1, fmt.Errorf("rekor entry: %w", err)
+		return nil, -1, fmt.Errorf("marshalling rekor entry: %w", err)
 	}
 	req, err := retryablehttp.NewRequest(http.MethodPost, hostPath, bytes.NewBuffer(body))
 	if err != nil {
@@ -264,99 +403,110 @@ func makeRekorV1Request(cert *x509.Certificate, priv *ecdsa.PrivateKey, hostPath
 }
 
 // rekorV1WriteEndpoint tests the write endpoint for rekor v1, which is
-// /api/v1/log/entries and adds an entry to the log
+// /api/v1/log/entries and adds an entry of each of --rekor-v1-entry-kinds to
+// the log, recorded as probe
 // if a certificate is provided, the Rekor entry will contain that certificate,
 // otherwise the provided key is used
-func rekorV1WriteEndpoint(ctx context.Context, cert *x509.Certificate, priv *ecdsa.PrivateKey, rekorV1Services []root.Service, trustedRoot *root.TrustedRoot) error {
-	var lastErr error
-	for _, s := range rekorV1Services {
-		verified := "false"
-		endpoint := rekorEndpoint
-		hostPath := s.URL + endpoint
-		defer func() {
-			verificationCounter.With(prometheus.Labels{verifiedLabel: verified}).Inc()
-		}()
-		var resp *http.Response
-		var latency int64
-		var err error
-		// A new body should be created when it is conflicted
-		for i := 1; i < 10; i++ {
-			resp, latency, err = makeRekorV1Request(cert, priv, hostPath)
-			if err != nil {
-				lastErr = fmt.Errorf("error adding entry: %w", err)
-				break
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusConflict {
-				break
-			}
+func rekorV1WriteEndpoint(ctx context.Context, probe string, cert *x509.Certificate, key *signingKey, rekorV1Services []root.Service, trustedRoot *root.TrustedRoot) error {
+	var errs []error
+	for _, kind := range rekorV1EntryKindList {
+		kindProbe := probe
+		if kind != rekorV1KindHashedRekord {
+			kindProbe += "_" + kind
 		}
-		if err != nil || resp == nil {
-			continue
-		}
-		exportDataToPrometheus(resp, s.URL, endpoint, POST, latency)
+		errs = append(errs, writeToServices(kindProbe, key.name, rekorV1Services, func(s root.Service) error {
+			return rekorV1WriteService(ctx, kind, cert, key, s, trustedRoot)
+		}))
+	}
+	return errors.Join(errs...)
+}
 
-		if resp.StatusCode != http.StatusCreated {
-			body, _ := io.ReadAll(resp.Body)
-			lastErr = fmt.Errorf("invalid status code '%s' when creating entry in rekor: '%s'", resp.Status, string(body))
-			continue
+// rekorV1WriteService adds an entry of the given kind to a single Rekor v1
+// instance and verifies it.
+func rekorV1WriteService(ctx context.Context, kind string, cert *x509.Certificate, key *signingKey, s root.Service, trustedRoot *root.TrustedRoot) error {
+	verified := "false"
+	endpoint := rekorEndpoint
+	hostPath := s.URL + endpoint
+	defer func() {
+		verificationCounter.With(prometheus.Labels{hostLabel: s.URL, verifiedLabel: verified}).Inc()
+	}()
+	var proposed models.ProposedEntry
+	var entry types.EntryImpl
+	var resp *http.Response
+	var latency int64
+	var err error
+	// A new body should be created when it is conflicted
+	for i := 1; i < 10; i++ {
+		proposed, entry, err = rekorV1NewEntry(ctx, kind, cert, key)
+		if err != nil {
+			return fmt.Errorf("rekor %s entry: %w", kind, err)
 		}
-		// If entry was added successfully, we should verify it
-		var logEntry models.LogEntry
-		err = json.NewDecoder(resp.Body).Decode(&logEntry)
+		resp, latency, err = makeRekorV1Request(proposed, hostPath)
 		if err != nil {
-			body, _ := io.ReadAll(resp.Body)
-			lastErr = fmt.Errorf("error decoding the log entry with body '%s' and error: %w", string(body), err)
-			continue
+			return fmt.Errorf("error adding entry: %w", err)
 		}
-		var logEntryAnon models.LogEntryAnon
-		for _, e := range logEntry {
-			logEntryAnon = e
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
 			break
 		}
-		if err = cosign.VerifyTLogEntryOffline(ctx, &logEntryAnon, nil, trustedRoot); err == nil {
-			verified = "true"
-			return nil
-		}
-		lastErr = err
 	}
-	return lastErr
+	exportDataToPrometheus(resp, s.URL, endpoint, POST, latency)
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("invalid status code '%s' when creating entry in rekor: '%s'", resp.Status, string(body))
+	}
+	// If entry was added successfully, we should verify it
+	var logEntry models.LogEntry
+	err = json.NewDecoder(resp.Body).Decode(&logEntry)
+	if err != nil {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("error decoding the log entry with body '%s' and error: %w", string(body), err)
+	}
+	var entryID string
+	var logEntryAnon models.LogEntryAnon
+	for id, e := range logEntry {
+		entryID = id
+		logEntryAnon = e
+		break
+	}
+	if err = cosign.VerifyTLogEntryOffline(ctx, &logEntryAnon, nil, trustedRoot); err != nil {
+		return err
+	}
+	if err = rekorV1VerifyEntryBody(ctx, s.URL, entry, logEntryAnon); err != nil {
+		return err
+	}
+	verified = "true"
+	// The entry is valid, now make sure clients can find it again
+	if err := rekorV1ReadAfterWrite(s.URL, entryID, logEntryAnon, entry); err != nil {
+		return fmt.Errorf("reading back entry %s: %w", entryID, err)
+	}
+	return nil
 }
 
-func rekorV1EntryRequest(cert *x509.Certificate, priv *ecdsa.PrivateKey) ([]byte, error) {
+func rekorV1EntryRequest(cert *x509.Certificate, key *signingKey) (*hashedrekord.V001Entry, error) {
 	// sign payload
 	payload := []byte(time.Now().String())
-	signer, err := signature.LoadECDSASignerVerifier(priv, crypto.SHA256)
+	sig, err := key.signArtifact(payload)
 	if err != nil {
-		return nil, fmt.Errorf("loading signer verifier: %w", err)
+		return nil, fmt.Errorf("sign message: %w", err)
 	}
-	sig, err := signer.SignMessage(bytes.NewReader(payload))
+	hashAlgorithm, err := hashedrekordAlgorithm(key.hash())
 	if err != nil {
-		return nil, fmt.Errorf("sign message: %w", err)
+		return nil, err
 	}
 
-	var verifier []byte
-	if cert != nil {
-		certPEM, err := cryptoutils.MarshalCertificateToPEM(cert)
-		if err != nil {
-			return nil, fmt.Errorf("error marshalling certificate: %w", err)
-		}
-		verifier = certPEM
-	} else {
-		pubKeyPEM, err := cryptoutils.MarshalPublicKeyToPEM(priv.Public())
-		if err != nil {
-			return nil, fmt.Errorf("error marshalling public key: %w", err)
-		}
-		verifier = pubKeyPEM
+	verifier, err := rekorV1VerifierPEM(cert, key)
+	if err != nil {
+		return nil, err
 	}
 
-	h := sha256.Sum256(payload)
 	e := &hashedrekord.V001Entry{
 		HashedRekordObj: models.HashedrekordV001Schema{
 			Data: &models.HashedrekordV001SchemaData{
 				Hash: &models.HashedrekordV001SchemaDataHash{
-					Algorithm: conv.Pointer(models.HashedrekordV001SchemaDataHashAlgorithmSha256),
-					Value:     conv.Pointer(hex.EncodeToString(h[:])),
+					Algorithm: conv.Pointer(hashAlgorithm),
+					Value:     conv.Pointer(hex.EncodeToString(key.digest(payload))),
 				},
 			},
 			Signature: &models.HashedrekordV001SchemaSignature{
@@ -367,112 +517,98 @@ func rekorV1EntryRequest(cert *x509.Certificate, priv *ecdsa.PrivateKey) ([]byte
 			},
 		},
 	}
-	pe := &models.Hashedrekord{
-		APIVersion: conv.Pointer(e.APIVersion()),
-		Spec:       e.HashedRekordObj,
+	return e, nil
+}
+
+// hashedrekordAlgorithm returns the name of hash in hashedrekord entries.
+func hashedrekordAlgorithm(hash crypto.Hash) (string, error) {
+	switch hash {
+	case crypto.SHA256:
+		return models.HashedrekordV001SchemaDataHashAlgorithmSha256, nil
+	case crypto.SHA384:
+		return models.HashedrekordV001SchemaDataHashAlgorithmSha384, nil
+	case crypto.SHA512:
+		return models.HashedrekordV001SchemaDataHashAlgorithmSha512, nil
+	default:
+		return "", fmt.Errorf("hash %s is not supported by hashedrekord entries", hash)
 	}
-	return json.Marshal(pe)
 }
 
 // rekorV2WriteEndpoint tests the write endpoint for rekor v2, which is
-// /api/v2/log/entries and adds an entry to the log
+// /api/v2/log/entries and adds an entry of each of --rekor-v2-entry-kinds to
+// the log, recorded as probe; the entries are added to integrations to wait
+// for them to become visible
 // if a certificate is provided, the Rekor entry will contain that certificate,
 // otherwise the provided key is used
-func rekorV2WriteEndpoint(ctx context.Context, cert *x509.Certificate, priv *ecdsa.PrivateKey, rekorV2Services []root.Service) error {
+func rekorV2WriteEndpoint(ctx context.Context, probe string, cert *x509.Certificate, key *signingKey, rekorV2Services []root.Service, integrations *rekorV2Integrations, trustedRoot *root.TrustedRoot) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	artifact := []byte(time.Now().String())
-	digest := sha256.Sum256(artifact)
-	sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
-	if err != nil {
-		return err
-	}
-
-	verifier := &protobuf.Verifier{
-		KeyDetails: common.PublicKeyDetails_PKIX_ECDSA_P256_SHA_256,
-	}
-	if cert != nil {
-		verifier.Verifier = &protobuf.Verifier_X509Certificate{
-			X509Certificate: &common.X509Certificate{
-				RawBytes: cert.Raw,
-			},
+	var errs []error
+	for _, kind := range rekorV2EntryKindList {
+		createEntryRequest, err := rekorV2NewEntry(kind, cert, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rekor v2 %s entry: %w", kind, err))
+			continue
 		}
-	} else {
-		pubBytes, err := cryptoutils.MarshalPublicKeyToDER(priv.Public())
+		reqBytes, err := protojson.Marshal(createEntryRequest)
 		if err != nil {
-			return err
+			errs = append(errs, err)
+			continue
 		}
-		verifier.Verifier = &protobuf.Verifier_PublicKey{
-			PublicKey: &protobuf.PublicKey{
-				RawBytes: pubBytes,
-			},
+
+		proberCheck := ReadProberCheck{
+			Endpoint: rekorV2Endpoint,
+			Method:   POST,
+			Body:     reqBytes,
+		}
+		kindProbe := probe
+		if kind != rekorV2KindHashedRekord {
+			kindProbe += "_" + kind
 		}
+		errs = append(errs, writeToServices(kindProbe, key.name, rekorV2Services, func(rekorV2Service root.Service) error {
+			start := time.Now()
+			tle, err := rekorV2WriteService(rekorV2Service, proberCheck, createEntryRequest, trustedRoot)
+			if err != nil {
+				return err
+			}
+			integrations.add(rekorV2Service, tle, start)
+			return nil
+		}))
 	}
+	return errors.Join(errs...)
+}
 
-	createEntryRequest := &protobuf.CreateEntryRequest{
-		Spec: &protobuf.CreateEntryRequest_HashedRekordRequestV002{
-			HashedRekordRequestV002: &protobuf.HashedRekordRequestV002{
-				Signature: &protobuf.Signature{
-					Content:  sig,
-					Verifier: verifier,
-				},
-				Digest: digest[:],
-			},
-		},
-	}
-	reqBytes, err := protojson.Marshal(createEntryRequest)
+// rekorV2WriteService adds an entry to a single Rekor v2 instance and
+// verifies the returned transparency log entry.
+func rekorV2WriteService(s root.Service, proberCheck ReadProberCheck, submitted *protobuf.CreateEntryRequest, trustedRoot *root.TrustedRoot) (*rekor.TransparencyLogEntry, error) {
+	verified := "false"
+	defer func() {
+		verificationCounter.With(prometheus.Labels{hostLabel: s.URL, verifiedLabel: verified}).Inc()
+	}()
+	respBytes, err := observeRequest(s.URL, proberCheck)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	proberCheck := ReadProberCheck{
-		Endpoint: rekorV2Endpoint,
-		Method:   POST,
-		Body:     reqBytes,
+	tle := rekor.TransparencyLogEntry{}
+	if err := protojson.Unmarshal(respBytes, &tle); err != nil {
+		return nil, err
 	}
-	var lastErr error
-	for _, rekorV2Service := range rekorV2Services {
-		respBytes, err := observeRequest(rekorV2Service.URL, proberCheck)
-		if err != nil {
-			lastErr = err
-			continue
-		}
-		tle := rekor.TransparencyLogEntry{}
-		if err := protojson.Unmarshal(respBytes, &tle); err != nil {
-			lastErr = err
-			continue
-		}
-		tleBody := protobuf.Entry{}
-		if err := protojson.Unmarshal(tle.CanonicalizedBody, &tleBody); err != nil {
-			lastErr = err
-			continue
-		}
-		// basic content matching, without proof or signature verification.
-		tleBodyDigest := tleBody.Spec.GetHashedRekordV002().Data.Digest
-		if !bytes.Equal(tleBodyDigest, digest[:]) {
-			lastErr = fmt.Errorf("tleEntry digest does not match: got: %s, want: %s", tleBodyDigest, digest)
-			continue
-		}
-		tleEntrySig := tleBody.Spec.GetHashedRekordV002().Signature.Content
-		if !bytes.Equal(tleEntrySig, sig) {
-			lastErr = fmt.Errorf("tleEntry signature does not match: got: %s, want: %s", tleEntrySig, sig)
-			continue
-		}
-		return nil
+	if err := rekorV2VerifyEntry(s.URL, &tle, submitted, trustedRoot); err != nil {
+		return nil, err
 	}
-	return lastErr
+	verified = "true"
+	return &tle, nil
 }
 
-func tsaWriteEndpoint(ctx context.Context, priv *ecdsa.PrivateKey, tsaServices []root.Service, trustedRoot *root.TrustedRoot) error {
+func tsaWriteEndpoint(ctx context.Context, key *signingKey, tsaServices []root.Service, trustedRoot *root.TrustedRoot) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
 
 	artifact := []byte(time.Now().String())
-	digest := sha256.Sum256(artifact)
-	sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+	sig, err := key.signArtifact(artifact)
 	if err != nil {
 		return err
 	}
@@ -492,47 +628,78 @@ func tsaWriteEndpoint(ctx context.Context, priv *ecdsa.PrivateKey, tsaServices [
 	proberCheck := TSAEndpoints[0]
 	proberCheck.Body = getTSReqBytes
 
-	var lastErr error
-	for _, tsaService := range tsaServices {
+	return writeToServices(tsaWriteProbe, key.name, tsaServices, func(tsaService root.Service) error {
 		getTSRespBytes, err := observeRequest(tsaService.URL, proberCheck)
 		if err != nil {
-			lastErr = err
-			continue
+			return err
 		}
+		var lastErr error
 		for _, tsa := range trustedRoot.TimestampingAuthorities() {
 			if _, err := tsa.Verify(getTSRespBytes, sig); err == nil {
 				return nil
 			}
 			lastErr = err
 		}
+		if lastErr != nil {
+			return fmt.Errorf("verifying the timestamp: %w", lastErr)
+		}
+		return errors.New("no trusted timestamp authority was able to verify the timestamp")
+	})
+}
+
+// writeToServices runs write against every service independently, records
+// the outcome for each, and combines the results according to writePolicy.
+// Having no services to write to is an error, so that a signing config that
+// loses all its services of a kind does not pass silently.
+func writeToServices(probe, algorithm string, services []root.Service, write func(root.Service) error) error {
+	if len(services) == 0 {
+		return fmt.Errorf("no services for %s", probe)
+	}
+	var errs []error
+	for _, s := range services {
+		err := write(s)
+		writeProbeCounter.With(prometheus.Labels{probeLabel: probe, hostLabel: s.URL, algorithmLabel: algorithm, successLabel: strconv.FormatBool(err == nil)}).Inc()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.URL, err))
+		}
 	}
-	if lastErr != nil {
-		return fmt.Errorf("verifying the timestamp: %w", lastErr)
+	if writePolicy == writePolicyAny && len(errs) < len(services) {
+		for _, err := range errs {
+			Logger.Warnf("ignoring %s failure as another service succeeded: %v", probe, err)
+		}
+		return nil
 	}
+	return errors.Join(errs...)
+}
 
-	return errors.New("no trusted timestamp authority was able to verify the timestamp")
+// certificateRequestForMode builds the JSON body of a request for a
+// certificate in the given --fulcio-request-mode.
+func certificateRequestForMode(ctx context.Context, idToken string, key *signingKey, mode string) ([]byte, error) {
+	if mode == fulcioRequestModeCSR {
+		return csrCertificateRequest(ctx, idToken, key)
+	}
+	return certificateRequest(ctx, idToken, key)
 }
 
-func certificateRequest(_ context.Context, idToken string, priv *ecdsa.PrivateKey) ([]byte, error) {
-	pubBytesPEM, err := cryptoutils.MarshalPublicKeyToPEM(priv.Public())
+func certificateRequest(_ context.Context, idToken string, key *signingKey) ([]byte, error) {
+	pubBytesPEM, err := cryptoutils.MarshalPublicKeyToPEM(key.Public())
 	if err != nil {
 		return nil, err
 	}
 
-	tok, err := oauthflow.OIDConnect(defaultOIDCIssuer, defaultOIDCClientID, "", "", &oauthflow.StaticTokenGetter{RawToken: idToken})
+	tok, err := oauthflow.OIDConnect(oidcIssuer, oidcClientID, "", "", &oauthflow.StaticTokenGetter{RawToken: idToken})
 	if err != nil {
 		return nil, err
 	}
 
 	// Sign the email address as part of the request
-	h := sha256.Sum256([]byte(tok.Subject))
-	proof, err := ecdsa.SignASN1(rand.Reader, priv, h[:])
+	proof, err := key.proveSubject(tok.Subject)
 	if err != nil {
 		return nil, err
 	}
 
 	req := SigningCertificateRequest{
-		PublicKeyRequest: PublicKeyRequest{
+		PublicKeyRequest: &PublicKeyRequest{
 			PublicKey: PublicKey{
 				Content: string(pubBytesPEM),
 			},
@@ -543,20 +710,41 @@ func certificateRequest(_ context.Context, idToken string, priv *ecdsa.PrivateKe
 	return json.Marshal(req)
 }
 
-func legacyCertificateRequest(_ context.Context, idToken string, priv *ecdsa.PrivateKey) ([]byte, error) {
-	pubBytesPEM, err := cryptoutils.MarshalPublicKeyToPEM(priv.Public())
+// csrCertificateRequest builds a request for a certificate with a PKCS#10
+// certificate signing request, whose signature is the proof of possession.
+func csrCertificateRequest(_ context.Context, idToken string, key *signingKey) ([]byte, error) {
+	tok, err := oauthflow.OIDConnect(oidcIssuer, oidcClientID, "", "", &oauthflow.StaticTokenGetter{RawToken: idToken})
+	if err != nil {
+		return nil, err
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: tok.Subject},
+	}, key)
+	if err != nil {
+		return nil, fmt.Errorf("creating certificate signing request: %w", err)
+	}
+
+	req := SigningCertificateRequest{
+		CertificateSigningRequest: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+	}
+
+	return json.Marshal(req)
+}
+
+func legacyCertificateRequest(_ context.Context, idToken string, key *signingKey) ([]byte, error) {
+	pubBytesPEM, err := cryptoutils.MarshalPublicKeyToPEM(key.Public())
 	if err != nil {
 		return nil, err
 	}
 
-	tok, err := oauthflow.OIDConnect(defaultOIDCIssuer, defaultOIDCClientID, "", "", &oauthflow.StaticTokenGetter{RawToken: idToken})
+	tok, err := oauthflow.OIDConnect(oidcIssuer, oidcClientID, "", "", &oauthflow.StaticTokenGetter{RawToken: idToken})
 	if err != nil {
 		return nil, err
 	}
 
 	// Sign the email address as part of the request
-	h := sha256.Sum256([]byte(tok.Subject))
-	proof, err := ecdsa.SignASN1(rand.Reader, priv, h[:])
+	proof, err := key.proveSubject(tok.Subject)
 	if err != nil {
 		return nil, err
 	}
@@ -572,7 +760,10 @@ func legacyCertificateRequest(_ context.Context, idToken string, priv *ecdsa.Pri
 }
 
 type SigningCertificateRequest struct {
-	PublicKeyRequest PublicKeyRequest `json:"publicKeyRequest"`
+	PublicKeyRequest *PublicKeyRequest `json:"publicKeyRequest,omitempty"`
+	// CertificateSigningRequest is a PEM-encoded PKCS#10 CSR, sent instead
+	// of PublicKeyRequest.
+	CertificateSigningRequest []byte `json:"certificateSigningRequest,omitempty"`
 }
 
 type SigningCertificateRequestLegacy struct {
@@ -581,13 +772,36 @@ type SigningCertificateRequestLegacy struct {
 }
 
 type SigningCertificateResponse struct {
-	CertificatesWithSct SignedCertificateEmbeddedSct `json:"signedCertificateEmbeddedSct"`
+	CertificatesWithSct     *SignedCertificateEmbeddedSct `json:"signedCertificateEmbeddedSct"`
+	CertificatesDetachedSct *SignedCertificateDetachedSct `json:"signedCertificateDetachedSct"`
+}
+
+// certificateChain returns the PEM-encoded certificate chain from whichever
+// of the two response shapes Fulcio returned, along with the detached SCT if
+// the SCT is not embedded in the certificate.
+func (r SigningCertificateResponse) certificateChain() ([]string, []byte, error) {
+	switch {
+	case r.CertificatesWithSct != nil:
+		return r.CertificatesWithSct.CertificateChain.Certificates, nil, nil
+	case r.CertificatesDetachedSct != nil:
+		if len(r.CertificatesDetachedSct.SignedCertificateTimestamp) == 0 {
+			return nil, nil, errors.New("response with detached SCT has an empty SCT")
+		}
+		return r.CertificatesDetachedSct.CertificateChain.Certificates, r.CertificatesDetachedSct.SignedCertificateTimestamp, nil
+	default:
+		return nil, nil, errors.New("response has neither an embedded nor a detached SCT certificate")
+	}
 }
 
 type SignedCertificateEmbeddedSct struct {
 	CertificateChain CertificateChain `json:"chain"`
 }
 
+type SignedCertificateDetachedSct struct {
+	CertificateChain           CertificateChain `json:"chain"`
+	SignedCertificateTimestamp []byte           `json:"signedCertificateTimestamp"`
+}
+
 type CertificateChain struct {
 	Certificates []string `json:"certificates"`
 }
@@ -0,0 +1,73 @@
+// Copyright 2026 The Sigstore Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"go.uber.org/zap"
+)
+
+func TestWriteToServices(t *testing.T) {
+	Logger = proberLogger{zap.NewNop().Sugar()}
+	oldWritePolicy := writePolicy
+	t.Cleanup(func() { writePolicy = oldWritePolicy })
+	errWrite := errors.New("write failed")
+
+	tests := []struct {
+		name    string
+		policy  string
+		results []error
+		wantErr bool
+	}{
+		{name: "all, no services", policy: writePolicyAll, results: nil, wantErr: true},
+		{name: "any, no services", policy: writePolicyAny, results: nil, wantErr: true},
+		{name: "all, all succeed", policy: writePolicyAll, results: []error{nil, nil}},
+		{name: "all, one fails", policy: writePolicyAll, results: []error{nil, errWrite}, wantErr: true},
+		{name: "all, all fail", policy: writePolicyAll, results: []error{errWrite, errWrite}, wantErr: true},
+		{name: "any, all succeed", policy: writePolicyAny, results: []error{nil, nil}},
+		{name: "any, one fails", policy: writePolicyAny, results: []error{errWrite, nil}},
+		{name: "any, all fail", policy: writePolicyAny, results: []error{errWrite, errWrite}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writePolicy = tt.policy
+			var services []root.Service
+			results := map[string]error{}
+			for i, err := range tt.results {
+				url := fmt.Sprintf("https://service%d.example.com", i)
+				services = append(services, root.Service{URL: url})
+				results[url] = err
+			}
+			var written []string
+			err := writeToServices("test_write", "test-algorithm", services, func(s root.Service) error {
+				written = append(written, s.URL)
+				return results[s.URL]
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("writeToServices() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(written) != len(services) {
+				t.Errorf("wrote to %d services, want all %d", len(written), len(services))
+			}
+			if tt.wantErr && len(tt.results) > 0 && !errors.Is(err, errWrite) {
+				t.Errorf("writeToServices() error = %v, want it to wrap the write error", err)
+			}
+		})
+	}
+}